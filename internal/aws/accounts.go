@@ -0,0 +1,150 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultWorkers is how many accounts are queried at once by default
+const DefaultWorkers = 4
+
+// Target is an account to query: a shared-config profile, optionally used to
+// assume RoleArn
+type Target struct {
+	Profile string
+	RoleArn string
+}
+
+// Account returns a label for t that needs no API call: the account ID of the
+// assumed role, or the profile name. ResolveAccount returns the account ID of
+// a profile.
+func (t Target) Account() string {
+	if t.RoleArn == "" {
+		return t.Profile
+	}
+	// arn:aws:iam::<account>:role/<name>
+	if parts := strings.Split(t.RoleArn, ":"); len(parts) >= 6 && parts[4] != "" {
+		return parts[4]
+	}
+	return t.RoleArn
+}
+
+// CallerIdentityAPI is the part of the STS API used to find the account of a
+// profile
+type CallerIdentityAPI interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// NewCallerIdentityClient creates an STS client for t's profile
+func NewCallerIdentityClient(ctx context.Context, t Target) (CallerIdentityAPI, error) {
+	cfg, err := loadConfig(ctx, Target{Profile: t.Profile})
+	if err != nil {
+		return nil, err
+	}
+	return sts.NewFromConfig(cfg), nil
+}
+
+// ResolveAccount returns the account ID results from t are tagged with, so
+// profile and role targets are labelled alike and match account metadata.
+// Role targets take the ID from their ARN; profiles ask api.
+func ResolveAccount(ctx context.Context, t Target, api CallerIdentityAPI) (string, error) {
+	if t.RoleArn != "" {
+		return t.Account(), nil
+	}
+	output, err := api.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve account of profile %s: %w", t.Profile, err)
+	}
+	return aws.ToString(output.Account), nil
+}
+
+// NewClientForTarget creates a Cost Explorer client for t, rate limited and
+// retried as configured by throttle. Role credentials are fetched on the first
+// request and refreshed as they expire.
+func NewClientForTarget(ctx context.Context, t Target, throttle Throttle) (*Client, error) {
+	cfg, err := loadConfig(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+
+	// retries are handled by the throttle, so the sdk makes a single attempt
+	ce := costexplorer.NewFromConfig(cfg, func(o *costexplorer.Options) {
+		o.Retryer = aws.NopRetryer{}
+	})
+	return &Client{
+		ce: NewThrottledAPI(ce, throttle),
+	}, nil
+}
+
+// loadConfig loads the shared config for t's profile, switching to role
+// credentials when t has a role
+func loadConfig(ctx context.Context, t Target) (aws.Config, error) {
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithSharedConfigProfile(t.Profile),
+	)
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	if t.RoleArn != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), t.RoleArn, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = "dab-cloudcost"
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+	return cfg, nil
+}
+
+// AccountClient is a client for one account of a multi-account query
+type AccountClient struct {
+	Account string
+	Client  *Client
+}
+
+// GetCostsForAccounts runs q against every account, with at most workers
+// queries in flight, and merges the results largest first with Account set on
+// each. The page count is the total across accounts.
+func GetCostsForAccounts(ctx context.Context, accounts []AccountClient, workers int, q CostQuery) ([]CostResult, int, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	perAccount := make([][]CostResult, len(accounts))
+	pages := make([]int, len(accounts))
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
+	for i, a := range accounts {
+		g.Go(func() error {
+			results, n, err := a.Client.GetCosts(ctx, q)
+			if err != nil {
+				return fmt.Errorf("account %s: %w", a.Account, err)
+			}
+			for j := range results {
+				results[j].Account = a.Account
+			}
+			perAccount[i] = results
+			pages[i] = n
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, 0, err
+	}
+
+	var merged []CostResult
+	total := 0
+	for i := range accounts {
+		merged = append(merged, perAccount[i]...)
+		total += pages[i]
+	}
+	return SortByAmount(merged), total, nil
+}
@@ -0,0 +1,144 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+func serviceOutput(amounts map[string]string) *costexplorer.GetCostAndUsageOutput {
+	var groups []types.Group
+	for service, amount := range amounts {
+		groups = append(groups, types.Group{
+			Keys: []string{service},
+			Metrics: map[string]types.MetricValue{
+				DefaultMetric: {Amount: aws.String(amount), Unit: aws.String("USD")},
+			},
+		})
+	}
+	return &costexplorer.GetCostAndUsageOutput{
+		ResultsByTime: []types.ResultByTime{{
+			TimePeriod: &types.DateInterval{Start: aws.String("2024-03-01"), End: aws.String("2024-04-01")},
+			Groups:     groups,
+		}},
+	}
+}
+
+func TestGetCostsForAccounts(t *testing.T) {
+	accounts := []AccountClient{
+		{Account: "111111111111", Client: NewClientWithAPI(&mockCostExplorer{output: serviceOutput(map[string]string{"Amazon EC2": "40"})})},
+		{Account: "222222222222", Client: NewClientWithAPI(&mockCostExplorer{output: serviceOutput(map[string]string{"Amazon EC2": "75"})})},
+		{Account: "333333333333", Client: NewClientWithAPI(&mockCostExplorer{output: serviceOutput(map[string]string{"Amazon S3": "10"})})},
+	}
+	q := CostQuery{Period: period.LastDays(30, time.Now()), Granularity: types.GranularityMonthly}
+
+	results, pages, err := GetCostsForAccounts(context.Background(), accounts, 2, q)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if pages != 3 {
+		t.Errorf("pages: got %d, want 3", pages)
+	}
+	if len(results) != 3 {
+		t.Fatalf("length: got %d, want 3", len(results))
+	}
+	expected := []struct {
+		account string
+		amount  float64
+	}{
+		{"222222222222", 75},
+		{"111111111111", 40},
+		{"333333333333", 10},
+	}
+	for i, e := range expected {
+		if results[i].Account != e.account || results[i].Amount != e.amount {
+			t.Errorf("index %d: got %s %.2f, want %s %.2f", i, results[i].Account, results[i].Amount, e.account, e.amount)
+		}
+	}
+}
+
+func TestGetCostsForAccountsError(t *testing.T) {
+	accounts := []AccountClient{
+		{Account: "ok", Client: NewClientWithAPI(&mockCostExplorer{output: serviceOutput(map[string]string{"Amazon EC2": "1"})})},
+		{Account: "broken", Client: NewClientWithAPI(&mockCostExplorer{err: errors.New("access denied")})},
+	}
+
+	_, _, err := GetCostsForAccounts(context.Background(), accounts, DefaultWorkers, CostQuery{Period: period.LastDays(7, time.Now())})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if got := err.Error(); got != "account broken: page 1: access denied" {
+		t.Errorf("error: got %q", got)
+	}
+}
+
+func TestTargetAccount(t *testing.T) {
+	tests := []struct {
+		target   Target
+		expected string
+	}{
+		{target: Target{Profile: "prod"}, expected: "prod"},
+		{target: Target{Profile: "mgmt", RoleArn: "arn:aws:iam::123456789012:role/CostReader"}, expected: "123456789012"},
+		{target: Target{RoleArn: "not-an-arn"}, expected: "not-an-arn"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := tt.target.Account(); got != tt.expected {
+				t.Errorf("got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+type mockCallerIdentity struct {
+	account string
+	err     error
+	calls   int
+}
+
+func (m *mockCallerIdentity) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &sts.GetCallerIdentityOutput{Account: aws.String(m.account)}, nil
+}
+
+func TestResolveAccount(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    Target
+		mock      *mockCallerIdentity
+		expected  string
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "profile", target: Target{Profile: "prod"}, mock: &mockCallerIdentity{account: "111111111111"}, expected: "111111111111", wantCalls: 1},
+		{name: "role", target: Target{Profile: "mgmt", RoleArn: "arn:aws:iam::222222222222:role/CostReader"}, mock: &mockCallerIdentity{}, expected: "222222222222"},
+		{name: "error", target: Target{Profile: "prod"}, mock: &mockCallerIdentity{err: errors.New("expired token")}, wantCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveAccount(context.Background(), tt.target, tt.mock)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error: got %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("got %q, want %q", got, tt.expected)
+			}
+			if tt.mock.calls != tt.wantCalls {
+				t.Errorf("sts calls: got %d, want %d", tt.mock.calls, tt.wantCalls)
+			}
+		})
+	}
+}
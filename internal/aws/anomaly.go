@@ -0,0 +1,141 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+// Anomaly is a Cost Anomaly Detection finding
+type Anomaly struct {
+	ID            string      `json:"id"`
+	Start         string      `json:"start"`
+	End           string      `json:"end,omitempty"`
+	MonitorArn    string      `json:"monitor_arn"`
+	Dimension     string      `json:"dimension,omitempty"`
+	Impact        float64     `json:"impact"`
+	ImpactPercent float64     `json:"impact_percent,omitempty"`
+	ActualSpend   float64     `json:"actual_spend,omitempty"`
+	ExpectedSpend float64     `json:"expected_spend,omitempty"`
+	Feedback      string      `json:"feedback,omitempty"`
+	RootCauses    []RootCause `json:"root_causes,omitempty"`
+}
+
+// RootCause is a dimension combination that contributed to an anomaly
+type RootCause struct {
+	Service      string  `json:"service,omitempty"`
+	Account      string  `json:"account,omitempty"`
+	AccountName  string  `json:"account_name,omitempty"`
+	Region       string  `json:"region,omitempty"`
+	UsageType    string  `json:"usage_type,omitempty"`
+	Contribution float64 `json:"contribution,omitempty"`
+}
+
+// AnomalyQuery selects anomalies detected within Period. MonitorArn,
+// MinImpact and Feedback are optional.
+type AnomalyQuery struct {
+	Period     period.Range
+	MonitorArn string
+	MinImpact  float64
+	Feedback   types.AnomalyFeedbackType
+}
+
+// GetAnomalies returns every anomaly matching q, largest impact first
+func (c *Client) GetAnomalies(ctx context.Context, q AnomalyQuery) ([]Anomaly, error) {
+	var anomalies []Anomaly
+	var token *string
+
+	for {
+		input := q.Input()
+		input.NextPageToken = token
+		output, err := c.ce.GetAnomalies(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range output.Anomalies {
+			anomalies = append(anomalies, parseAnomaly(a))
+		}
+
+		if aws.ToString(output.NextPageToken) == "" {
+			break
+		}
+		token = output.NextPageToken
+	}
+
+	sortByAmount(anomalies, func(a Anomaly) float64 { return a.Impact })
+	return anomalies, nil
+}
+
+// Input builds the GetAnomalies request for q. Cost Explorer treats the end
+// date as inclusive, so it is the day before q.Period.End.
+func (q AnomalyQuery) Input() *costexplorer.GetAnomaliesInput {
+	input := &costexplorer.GetAnomaliesInput{
+		DateInterval: &types.AnomalyDateInterval{
+			StartDate: aws.String(q.Period.Start.Format(period.DateLayout)),
+			EndDate:   aws.String(q.Period.End.AddDate(0, 0, -1).Format(period.DateLayout)),
+		},
+		Feedback: q.Feedback,
+	}
+	if q.MonitorArn != "" {
+		input.MonitorArn = aws.String(q.MonitorArn)
+	}
+	if q.MinImpact > 0 {
+		input.TotalImpact = &types.TotalImpactFilter{
+			NumericOperator: types.NumericOperatorGreaterThanOrEqual,
+			StartValue:      q.MinImpact,
+		}
+	}
+	return input
+}
+
+// ParseFeedback converts a feedback name (yes, no, planned-activity) into its
+// Cost Explorer value
+func ParseFeedback(s string) (types.AnomalyFeedbackType, error) {
+	if s == "" {
+		return "", nil
+	}
+	value := types.AnomalyFeedbackType(strings.ToUpper(strings.ReplaceAll(s, "-", "_")))
+	for _, f := range value.Values() {
+		if f == value {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown feedback %q (want yes, no or planned-activity)", s)
+}
+
+func parseAnomaly(a types.Anomaly) Anomaly {
+	anomaly := Anomaly{
+		ID:         aws.ToString(a.AnomalyId),
+		Start:      aws.ToString(a.AnomalyStartDate),
+		End:        aws.ToString(a.AnomalyEndDate),
+		MonitorArn: aws.ToString(a.MonitorArn),
+		Dimension:  aws.ToString(a.DimensionValue),
+		Feedback:   string(a.Feedback),
+	}
+	if a.Impact != nil {
+		anomaly.Impact = a.Impact.TotalImpact
+		anomaly.ImpactPercent = aws.ToFloat64(a.Impact.TotalImpactPercentage)
+		anomaly.ActualSpend = aws.ToFloat64(a.Impact.TotalActualSpend)
+		anomaly.ExpectedSpend = aws.ToFloat64(a.Impact.TotalExpectedSpend)
+	}
+	for _, rc := range a.RootCauses {
+		cause := RootCause{
+			Service:     aws.ToString(rc.Service),
+			Account:     aws.ToString(rc.LinkedAccount),
+			AccountName: aws.ToString(rc.LinkedAccountName),
+			Region:      aws.ToString(rc.Region),
+			UsageType:   aws.ToString(rc.UsageType),
+		}
+		if rc.Impact != nil {
+			cause.Contribution = rc.Impact.Contribution
+		}
+		anomaly.RootCauses = append(anomaly.RootCauses, cause)
+	}
+	return anomaly
+}
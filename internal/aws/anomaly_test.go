@@ -0,0 +1,132 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+func TestGetAnomalies(t *testing.T) {
+	window := period.Range{
+		Start: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC),
+	}
+
+	mock := &mockCostExplorer{
+		anomalies: []*costexplorer.GetAnomaliesOutput{
+			{
+				Anomalies: []types.Anomaly{
+					{
+						AnomalyId:        aws.String("a-small"),
+						AnomalyStartDate: aws.String("2024-03-02"),
+						Impact:           &types.Impact{TotalImpact: 15.5},
+					},
+				},
+				NextPageToken: aws.String("next"),
+			},
+			{
+				Anomalies: []types.Anomaly{
+					{
+						AnomalyId:        aws.String("a-large"),
+						AnomalyStartDate: aws.String("2024-03-06"),
+						MonitorArn:       aws.String("arn:aws:ce::111111111111:anomalymonitor/services"),
+						Feedback:         types.AnomalyFeedbackTypePlannedActivity,
+						Impact: &types.Impact{
+							TotalImpact:           420.0,
+							TotalImpactPercentage: aws.Float64(210.0),
+							TotalActualSpend:      aws.Float64(620.0),
+							TotalExpectedSpend:    aws.Float64(200.0),
+						},
+						RootCauses: []types.RootCause{
+							{
+								Service:       aws.String("Amazon Elastic Compute Cloud - Compute"),
+								LinkedAccount: aws.String("111111111111"),
+								Region:        aws.String("us-east-1"),
+								UsageType:     aws.String("BoxUsage:m5.24xlarge"),
+								Impact:        &types.RootCauseImpact{Contribution: 400.0},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	client := NewClientWithAPI(mock)
+	anomalies, err := client.GetAnomalies(context.Background(), AnomalyQuery{Period: window, MinImpact: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(mock.anomalyCalls) != 2 {
+		t.Fatalf("calls: got %d, want 2", len(mock.anomalyCalls))
+	}
+	input := mock.anomalyCalls[0]
+	if got := aws.ToString(input.DateInterval.EndDate); got != "2024-03-07" {
+		t.Errorf("end date: got %s, want 2024-03-07", got)
+	}
+	if input.TotalImpact == nil || input.TotalImpact.StartValue != 10 {
+		t.Errorf("total impact filter: got %+v", input.TotalImpact)
+	}
+	if got := aws.ToString(mock.anomalyCalls[1].NextPageToken); got != "next" {
+		t.Errorf("second call token: got %q, want next", got)
+	}
+
+	if len(anomalies) != 2 {
+		t.Fatalf("length: got %d, want 2", len(anomalies))
+	}
+	first := anomalies[0]
+	if first.ID != "a-large" {
+		t.Errorf("first: got %s, want a-large", first.ID)
+	}
+	if first.Feedback != "PLANNED_ACTIVITY" || first.ImpactPercent != 210.0 || first.ExpectedSpend != 200.0 {
+		t.Errorf("first fields: got %+v", first)
+	}
+	if len(first.RootCauses) != 1 || first.RootCauses[0].UsageType != "BoxUsage:m5.24xlarge" || first.RootCauses[0].Contribution != 400.0 {
+		t.Errorf("root causes: got %+v", first.RootCauses)
+	}
+}
+
+func TestGetAnomaliesError(t *testing.T) {
+	client := NewClientWithAPI(&mockCostExplorer{err: errors.New("api error")})
+	if _, err := client.GetAnomalies(context.Background(), AnomalyQuery{Period: period.LastDays(7, time.Now())}); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestParseFeedback(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected types.AnomalyFeedbackType
+		wantErr  bool
+	}{
+		{input: "", expected: ""},
+		{input: "yes", expected: types.AnomalyFeedbackTypeYes},
+		{input: "planned-activity", expected: types.AnomalyFeedbackTypePlannedActivity},
+		{input: "maybe", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := ParseFeedback(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("got %s, want %s", result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/cache"
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+)
+
+// cachedAPI serves GetCostAndUsage pages from a response cache. Other calls
+// go straight to the wrapped API.
+type cachedAPI struct {
+	CostExplorerAPI
+	store *cache.Cache
+	scope string
+}
+
+// WithCache returns a copy of c whose cost and usage pages are cached in
+// store. Scope identifies the account, so identical queries against different
+// accounts do not share entries. A nil store disables caching.
+func (c *Client) WithCache(store *cache.Cache, scope string) *Client {
+	if store == nil {
+		return c
+	}
+	return &Client{ce: &cachedAPI{CostExplorerAPI: c.ce, store: store, scope: scope}}
+}
+
+func (a *cachedAPI) GetCostAndUsage(ctx context.Context, params *costexplorer.GetCostAndUsageInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetCostAndUsageOutput, error) {
+	request, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	key := cache.Key("aws", a.scope, "GetCostAndUsage", string(request))
+
+	var output costexplorer.GetCostAndUsageOutput
+	if ok, _ := a.store.Get(key, &output); ok {
+		return &output, nil
+	}
+
+	fetched, err := a.CostExplorerAPI.GetCostAndUsage(ctx, params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	// a failed write only costs a refetch next time
+	a.store.Put(key, fetched, intervalEnd(params))
+	return fetched, nil
+}
+
+// intervalEnd returns the exclusive end of the queried period, or now when it
+// cannot be parsed so the entry expires with the TTL
+func intervalEnd(params *costexplorer.GetCostAndUsageInput) time.Time {
+	if params.TimePeriod == nil {
+		return time.Now()
+	}
+	end := aws.ToString(params.TimePeriod.End)
+	for _, layout := range []string{period.DateLayout, time.RFC3339} {
+		if t, err := time.Parse(layout, end); err == nil {
+			return t
+		}
+	}
+	return time.Now()
+}
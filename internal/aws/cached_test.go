@@ -0,0 +1,63 @@
+package aws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/cache"
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+func TestClientWithCache(t *testing.T) {
+	mock := &mockCostExplorer{
+		output: &costexplorer.GetCostAndUsageOutput{
+			ResultsByTime: []types.ResultByTime{
+				{
+					Groups: []types.Group{
+						{
+							Keys: []string{"Amazon EC2"},
+							Metrics: map[string]types.MetricValue{
+								"UnblendedCost": {Amount: aws.String("42.50"), Unit: aws.String("USD")},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	store := cache.New(t.TempDir(), time.Hour)
+	q := CostQuery{Period: period.LastDays(30, time.Now())}
+
+	prod := NewClientWithAPI(mock).WithCache(store, "prod")
+	for i := 0; i < 2; i++ {
+		results, _, err := prod.GetCosts(context.Background(), q)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(results) != 1 || results[0].Keys[0] != "Amazon EC2" || results[0].Amount != 42.5 {
+			t.Errorf("run %d: got %+v", i, results)
+		}
+	}
+	if len(mock.calls) != 1 {
+		t.Errorf("calls: got %d, want 1 (cached)", len(mock.calls))
+	}
+
+	staging := NewClientWithAPI(mock).WithCache(store, "staging")
+	if _, _, err := staging.GetCosts(context.Background(), q); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mock.calls) != 2 {
+		t.Errorf("calls: got %d, want 2 (scoped per account)", len(mock.calls))
+	}
+}
+
+func TestClientWithNilCache(t *testing.T) {
+	client := NewClientWithAPI(&mockCostExplorer{})
+	if client.WithCache(nil, "prod") != client {
+		t.Error("nil cache: got a new client")
+	}
+}
@@ -0,0 +1,253 @@
+package aws
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+// CommitmentQuery selects a Savings Plans or Reserved Instance report
+type CommitmentQuery struct {
+	Period      period.Range
+	Granularity types.Granularity
+	Filter      *types.Expression
+}
+
+// Utilization is how much of a commitment was used in a period. For Reserved
+// Instances the commitment is the amortized fee and unused is the cost of
+// unused hours.
+type Utilization struct {
+	Start              string  `json:"start,omitempty"`
+	End                string  `json:"end,omitempty"`
+	UtilizationPercent float64 `json:"utilization_percent"`
+	Commitment         float64 `json:"commitment"`
+	Used               float64 `json:"used"`
+	Unused             float64 `json:"unused"`
+	NetSavings         float64 `json:"net_savings"`
+}
+
+// UtilizationReport holds utilization per period and for the whole window
+type UtilizationReport struct {
+	Periods []Utilization `json:"periods"`
+	Total   Utilization   `json:"total"`
+}
+
+// Coverage is how much eligible usage a commitment covered in a period.
+// OnDemandCost is the spend that was not covered. Reserved Instance coverage
+// is measured in hours, so Covered and TotalCost are only set for Savings
+// Plans.
+type Coverage struct {
+	Start           string  `json:"start,omitempty"`
+	End             string  `json:"end,omitempty"`
+	CoveragePercent float64 `json:"coverage_percent"`
+	Covered         float64 `json:"covered,omitempty"`
+	OnDemandCost    float64 `json:"on_demand_cost"`
+	TotalCost       float64 `json:"total_cost,omitempty"`
+}
+
+// CoverageReport holds coverage per period and for the whole window
+type CoverageReport struct {
+	Periods []Coverage `json:"periods"`
+	Total   Coverage   `json:"total"`
+}
+
+func (q CommitmentQuery) granularity() types.Granularity {
+	if q.Granularity == "" {
+		return types.GranularityMonthly
+	}
+	return q.Granularity
+}
+
+// GetSavingsPlansUtilization returns Savings Plans utilization for q
+func (c *Client) GetSavingsPlansUtilization(ctx context.Context, q CommitmentQuery) (*UtilizationReport, error) {
+	output, err := c.ce.GetSavingsPlansUtilization(ctx, &costexplorer.GetSavingsPlansUtilizationInput{
+		TimePeriod:  dateInterval(q.Period, false),
+		Granularity: q.granularity(),
+		Filter:      q.Filter,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &UtilizationReport{}
+	for _, u := range output.SavingsPlansUtilizationsByTime {
+		p := savingsPlansUtilization(u.Utilization, u.Savings)
+		p.Start, p.End = intervalDates(u.TimePeriod)
+		report.Periods = append(report.Periods, p)
+	}
+	if output.Total != nil {
+		report.Total = savingsPlansUtilization(output.Total.Utilization, output.Total.Savings)
+	}
+	return report, nil
+}
+
+// GetReservationUtilization returns Reserved Instance utilization for q
+func (c *Client) GetReservationUtilization(ctx context.Context, q CommitmentQuery) (*UtilizationReport, error) {
+	report := &UtilizationReport{}
+	var token *string
+
+	for {
+		output, err := c.ce.GetReservationUtilization(ctx, &costexplorer.GetReservationUtilizationInput{
+			TimePeriod:    dateInterval(q.Period, false),
+			Granularity:   q.granularity(),
+			Filter:        q.Filter,
+			NextPageToken: token,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, u := range output.UtilizationsByTime {
+			p := reservationUtilization(u.Total)
+			p.Start, p.End = intervalDates(u.TimePeriod)
+			report.Periods = append(report.Periods, p)
+		}
+		if output.Total != nil {
+			report.Total = reservationUtilization(output.Total)
+		}
+
+		if aws.ToString(output.NextPageToken) == "" {
+			break
+		}
+		token = output.NextPageToken
+	}
+	return report, nil
+}
+
+// GetSavingsPlansCoverage returns Savings Plans coverage for q
+func (c *Client) GetSavingsPlansCoverage(ctx context.Context, q CommitmentQuery) (*CoverageReport, error) {
+	report := &CoverageReport{}
+	var token *string
+
+	for {
+		output, err := c.ce.GetSavingsPlansCoverage(ctx, &costexplorer.GetSavingsPlansCoverageInput{
+			TimePeriod:  dateInterval(q.Period, false),
+			Granularity: q.granularity(),
+			Filter:      q.Filter,
+			NextToken:   token,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cov := range output.SavingsPlansCoverages {
+			p := Coverage{}
+			p.Start, p.End = intervalDates(cov.TimePeriod)
+			if cov.Coverage != nil {
+				p.CoveragePercent = parseAmount(cov.Coverage.CoveragePercentage)
+				p.Covered = parseAmount(cov.Coverage.SpendCoveredBySavingsPlans)
+				p.OnDemandCost = parseAmount(cov.Coverage.OnDemandCost)
+				p.TotalCost = parseAmount(cov.Coverage.TotalCost)
+			}
+			report.Periods = append(report.Periods, p)
+
+			report.Total.Covered += p.Covered
+			report.Total.OnDemandCost += p.OnDemandCost
+			report.Total.TotalCost += p.TotalCost
+		}
+
+		if aws.ToString(output.NextToken) == "" {
+			break
+		}
+		token = output.NextToken
+	}
+
+	if report.Total.TotalCost > 0 {
+		report.Total.CoveragePercent = report.Total.Covered / report.Total.TotalCost * 100
+	}
+	return report, nil
+}
+
+// GetReservationCoverage returns Reserved Instance coverage for q
+func (c *Client) GetReservationCoverage(ctx context.Context, q CommitmentQuery) (*CoverageReport, error) {
+	report := &CoverageReport{}
+	var token *string
+
+	for {
+		output, err := c.ce.GetReservationCoverage(ctx, &costexplorer.GetReservationCoverageInput{
+			TimePeriod:    dateInterval(q.Period, false),
+			Granularity:   q.granularity(),
+			Filter:        q.Filter,
+			NextPageToken: token,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cov := range output.CoveragesByTime {
+			p := reservationCoverage(cov.Total)
+			p.Start, p.End = intervalDates(cov.TimePeriod)
+			report.Periods = append(report.Periods, p)
+		}
+		if output.Total != nil {
+			report.Total = reservationCoverage(output.Total)
+		}
+
+		if aws.ToString(output.NextPageToken) == "" {
+			break
+		}
+		token = output.NextPageToken
+	}
+	return report, nil
+}
+
+func savingsPlansUtilization(u *types.SavingsPlansUtilization, s *types.SavingsPlansSavings) Utilization {
+	result := Utilization{}
+	if u != nil {
+		result.UtilizationPercent = parseAmount(u.UtilizationPercentage)
+		result.Commitment = parseAmount(u.TotalCommitment)
+		result.Used = parseAmount(u.UsedCommitment)
+		result.Unused = parseAmount(u.UnusedCommitment)
+	}
+	if s != nil {
+		result.NetSavings = parseAmount(s.NetSavings)
+	}
+	return result
+}
+
+func reservationUtilization(a *types.ReservationAggregates) Utilization {
+	if a == nil {
+		return Utilization{}
+	}
+	commitment := parseAmount(a.TotalAmortizedFee)
+	unused := parseAmount(a.RICostForUnusedHours)
+	return Utilization{
+		UtilizationPercent: parseAmount(a.UtilizationPercentage),
+		Commitment:         commitment,
+		Used:               commitment - unused,
+		Unused:             unused,
+		NetSavings:         parseAmount(a.NetRISavings),
+	}
+}
+
+func reservationCoverage(c *types.Coverage) Coverage {
+	result := Coverage{}
+	if c == nil {
+		return result
+	}
+	if c.CoverageHours != nil {
+		result.CoveragePercent = parseAmount(c.CoverageHours.CoverageHoursPercentage)
+	}
+	if c.CoverageCost != nil {
+		result.OnDemandCost = parseAmount(c.CoverageCost.OnDemandCost)
+	}
+	return result
+}
+
+func intervalDates(i *types.DateInterval) (string, string) {
+	if i == nil {
+		return "", ""
+	}
+	return aws.ToString(i.Start), aws.ToString(i.End)
+}
+
+// parseAmount parses a Cost Explorer decimal string, treating missing or
+// malformed values as zero
+func parseAmount(s *string) float64 {
+	v, _ := strconv.ParseFloat(aws.ToString(s), 64)
+	return v
+}
@@ -0,0 +1,199 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+var commitmentWindow = period.Range{
+	Start: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	End:   time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+}
+
+func TestGetSavingsPlansUtilization(t *testing.T) {
+	mock := &mockCostExplorer{
+		spUtilization: &costexplorer.GetSavingsPlansUtilizationOutput{
+			SavingsPlansUtilizationsByTime: []types.SavingsPlansUtilizationByTime{
+				{
+					TimePeriod: &types.DateInterval{Start: aws.String("2024-03-01"), End: aws.String("2024-04-01")},
+					Utilization: &types.SavingsPlansUtilization{
+						TotalCommitment:       aws.String("100"),
+						UsedCommitment:        aws.String("90"),
+						UnusedCommitment:      aws.String("10"),
+						UtilizationPercentage: aws.String("90"),
+					},
+					Savings: &types.SavingsPlansSavings{NetSavings: aws.String("25.5")},
+				},
+			},
+			Total: &types.SavingsPlansUtilizationAggregates{
+				Utilization: &types.SavingsPlansUtilization{
+					TotalCommitment:       aws.String("200"),
+					UsedCommitment:        aws.String("170"),
+					UnusedCommitment:      aws.String("30"),
+					UtilizationPercentage: aws.String("85"),
+				},
+			},
+		},
+	}
+
+	report, err := NewClientWithAPI(mock).GetSavingsPlansUtilization(context.Background(), CommitmentQuery{Period: commitmentWindow})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(report.Periods) != 1 {
+		t.Fatalf("periods: got %d, want 1", len(report.Periods))
+	}
+	p := report.Periods[0]
+	if p.Start != "2024-03-01" || p.UtilizationPercent != 90 || p.Unused != 10 || p.NetSavings != 25.5 {
+		t.Errorf("period: got %+v", p)
+	}
+	if report.Total.Commitment != 200 || report.Total.Unused != 30 || report.Total.UtilizationPercent != 85 {
+		t.Errorf("total: got %+v", report.Total)
+	}
+}
+
+func TestGetReservationUtilization(t *testing.T) {
+	mock := &mockCostExplorer{
+		riUtilization: &costexplorer.GetReservationUtilizationOutput{
+			UtilizationsByTime: []types.UtilizationByTime{
+				{
+					TimePeriod: &types.DateInterval{Start: aws.String("2024-03-01"), End: aws.String("2024-04-01")},
+					Total: &types.ReservationAggregates{
+						TotalAmortizedFee:     aws.String("50"),
+						RICostForUnusedHours:  aws.String("12.5"),
+						UtilizationPercentage: aws.String("75"),
+						NetRISavings:          aws.String("8"),
+					},
+				},
+			},
+		},
+	}
+
+	report, err := NewClientWithAPI(mock).GetReservationUtilization(context.Background(), CommitmentQuery{Period: commitmentWindow})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(report.Periods) != 1 {
+		t.Fatalf("periods: got %d, want 1", len(report.Periods))
+	}
+	p := report.Periods[0]
+	if p.Commitment != 50 || p.Used != 37.5 || p.Unused != 12.5 || p.NetSavings != 8 {
+		t.Errorf("period: got %+v", p)
+	}
+}
+
+func TestGetSavingsPlansCoverage(t *testing.T) {
+	mock := &mockCostExplorer{
+		spCoverage: []*costexplorer.GetSavingsPlansCoverageOutput{
+			{
+				SavingsPlansCoverages: []types.SavingsPlansCoverage{
+					{
+						TimePeriod: &types.DateInterval{Start: aws.String("2024-03-01"), End: aws.String("2024-04-01")},
+						Coverage: &types.SavingsPlansCoverageData{
+							CoveragePercentage:         aws.String("60"),
+							SpendCoveredBySavingsPlans: aws.String("60"),
+							OnDemandCost:               aws.String("40"),
+							TotalCost:                  aws.String("100"),
+						},
+					},
+				},
+				NextToken: aws.String("next"),
+			},
+			{
+				SavingsPlansCoverages: []types.SavingsPlansCoverage{
+					{
+						TimePeriod: &types.DateInterval{Start: aws.String("2024-04-01"), End: aws.String("2024-05-01")},
+						Coverage: &types.SavingsPlansCoverageData{
+							CoveragePercentage:         aws.String("100"),
+							SpendCoveredBySavingsPlans: aws.String("100"),
+							OnDemandCost:               aws.String("0"),
+							TotalCost:                  aws.String("100"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	report, err := NewClientWithAPI(mock).GetSavingsPlansCoverage(context.Background(), CommitmentQuery{Period: commitmentWindow})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(mock.spCoverageCall) != 2 {
+		t.Fatalf("calls: got %d, want 2", len(mock.spCoverageCall))
+	}
+	if got := aws.ToString(mock.spCoverageCall[1].NextToken); got != "next" {
+		t.Errorf("second call token: got %q, want next", got)
+	}
+	if mock.spCoverageCall[0].Granularity != types.GranularityMonthly {
+		t.Errorf("granularity: got %s, want MONTHLY", mock.spCoverageCall[0].Granularity)
+	}
+
+	if len(report.Periods) != 2 {
+		t.Fatalf("periods: got %d, want 2", len(report.Periods))
+	}
+	total := report.Total
+	if total.Covered != 160 || total.OnDemandCost != 40 || total.TotalCost != 200 || total.CoveragePercent != 80 {
+		t.Errorf("total: got %+v", total)
+	}
+}
+
+func TestGetReservationCoverage(t *testing.T) {
+	mock := &mockCostExplorer{
+		riCoverage: &costexplorer.GetReservationCoverageOutput{
+			CoveragesByTime: []types.CoverageByTime{
+				{
+					TimePeriod: &types.DateInterval{Start: aws.String("2024-03-01"), End: aws.String("2024-04-01")},
+					Total: &types.Coverage{
+						CoverageHours: &types.CoverageHours{CoverageHoursPercentage: aws.String("40")},
+						CoverageCost:  &types.CoverageCost{OnDemandCost: aws.String("300")},
+					},
+				},
+			},
+			Total: &types.Coverage{
+				CoverageHours: &types.CoverageHours{CoverageHoursPercentage: aws.String("40")},
+				CoverageCost:  &types.CoverageCost{OnDemandCost: aws.String("300")},
+			},
+		},
+	}
+
+	report, err := NewClientWithAPI(mock).GetReservationCoverage(context.Background(), CommitmentQuery{Period: commitmentWindow})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(report.Periods) != 1 || report.Periods[0].CoveragePercent != 40 {
+		t.Errorf("periods: got %+v", report.Periods)
+	}
+	if report.Total.OnDemandCost != 300 {
+		t.Errorf("total on-demand: got %.2f, want 300", report.Total.OnDemandCost)
+	}
+}
+
+func TestCommitmentError(t *testing.T) {
+	client := NewClientWithAPI(&mockCostExplorer{err: errors.New("api error")})
+	q := CommitmentQuery{Period: commitmentWindow}
+
+	if _, err := client.GetSavingsPlansUtilization(context.Background(), q); err == nil {
+		t.Error("savings plans utilization: expected error, got nil")
+	}
+	if _, err := client.GetSavingsPlansCoverage(context.Background(), q); err == nil {
+		t.Error("savings plans coverage: expected error, got nil")
+	}
+	if _, err := client.GetReservationUtilization(context.Background(), q); err == nil {
+		t.Error("reservation utilization: expected error, got nil")
+	}
+	if _, err := client.GetReservationCoverage(context.Background(), q); err == nil {
+		t.Error("reservation coverage: expected error, got nil")
+	}
+}
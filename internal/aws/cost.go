@@ -2,25 +2,126 @@ package aws
 
 import (
 	"context"
+	"fmt"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
 	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
 )
 
+// CostResult is the cost of one group. Keys holds one value per GroupBy of the
+// query, in the same order. Amount, Unit and Periods use the first metric of
+// the query; Metrics holds every metric when more than one was requested.
+// Account is only set on results of a multi-account query, and AccountInfo
+// only once account metadata has been resolved.
 type CostResult struct {
-	Service string  `json:"service"`
-	Amount  float64 `json:"amount"`
-	Unit    string  `json:"unit"`
+	Account string             `json:"account,omitempty"`
+	Keys    []string           `json:"keys"`
+	Amount  float64            `json:"amount"`
+	Unit    string             `json:"unit"`
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+	Periods []PeriodCost       `json:"periods,omitempty"`
+
+	AccountInfo *AccountInfo `json:"account_info,omitempty"`
+}
+
+// PeriodCost is the cost of a single result within one time bucket. Metrics
+// is set like CostResult.Metrics.
+type PeriodCost struct {
+	Start   string             `json:"start"`
+	End     string             `json:"end"`
+	Amount  float64            `json:"amount"`
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+}
+
+// TimeSeriesPoint is the cost of a single result within one time bucket, in
+// long format
+type TimeSeriesPoint struct {
+	Start   string   `json:"start"`
+	End     string   `json:"end"`
+	Account string   `json:"account,omitempty"`
+	Keys    []string `json:"keys"`
+	Amount  float64  `json:"amount"`
+	Unit    string   `json:"unit"`
+
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+}
+
+// CostQuery describes a cost and usage query. GroupBy defaults to SERVICE and
+// Metrics to UnblendedCost. Filter is optional; see BuildFilter.
+type CostQuery struct {
+	Period      period.Range
+	Granularity types.Granularity
+	GroupBy     []GroupBy
+	Metrics     []string
+	Filter      *types.Expression
+}
+
+// DefaultMetric is the metric used when a query does not name one
+const DefaultMetric = "UnblendedCost"
+
+// Metrics lists the Cost Explorer metrics accepted by GetCostAndUsage
+var Metrics = []string{
+	"AmortizedCost",
+	"BlendedCost",
+	"NetAmortizedCost",
+	"NetUnblendedCost",
+	"NormalizedUsageAmount",
+	"UnblendedCost",
+	"UsageQuantity",
+}
+
+// MaxGroupBy is the number of groupings Cost Explorer accepts per query
+const MaxGroupBy = 2
+
+// GroupBy is a Cost Explorer grouping such as the SERVICE dimension
+type GroupBy struct {
+	Type types.GroupDefinitionType
+	Key  string
+}
+
+// String returns the group-by in the form accepted by ParseGroupBy
+func (g GroupBy) String() string {
+	switch g.Type {
+	case types.GroupDefinitionTypeTag:
+		return "tag:" + g.Key
+	case types.GroupDefinitionTypeCostCategory:
+		return "costcategory:" + g.Key
+	}
+	return strings.ToLower(g.Key)
+}
+
+// Untagged is the key shown for costs without a value for a tag or cost
+// category group-by
+const Untagged = "(untagged)"
+
+// ServiceGroupBy groups costs by AWS service
+var ServiceGroupBy = GroupBy{Type: types.GroupDefinitionTypeDimension, Key: "SERVICE"}
+
+// groupByAliases maps short names to Cost Explorer dimensions
+var groupByAliases = map[string]string{
+	"ACCOUNT": "LINKED_ACCOUNT",
 }
 
 // CostExplorerAPI interface for testing
 type CostExplorerAPI interface {
 	GetCostAndUsage(ctx context.Context, params *costexplorer.GetCostAndUsageInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetCostAndUsageOutput, error)
+	GetCostForecast(ctx context.Context, params *costexplorer.GetCostForecastInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetCostForecastOutput, error)
+	GetAnomalies(ctx context.Context, params *costexplorer.GetAnomaliesInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetAnomaliesOutput, error)
+	GetSavingsPlansUtilization(ctx context.Context, params *costexplorer.GetSavingsPlansUtilizationInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetSavingsPlansUtilizationOutput, error)
+	GetSavingsPlansCoverage(ctx context.Context, params *costexplorer.GetSavingsPlansCoverageInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetSavingsPlansCoverageOutput, error)
+	GetReservationUtilization(ctx context.Context, params *costexplorer.GetReservationUtilizationInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetReservationUtilizationOutput, error)
+	GetReservationCoverage(ctx context.Context, params *costexplorer.GetReservationCoverageInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetReservationCoverageOutput, error)
+	GetSavingsPlansPurchaseRecommendation(ctx context.Context, params *costexplorer.GetSavingsPlansPurchaseRecommendationInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetSavingsPlansPurchaseRecommendationOutput, error)
+	GetReservationPurchaseRecommendation(ctx context.Context, params *costexplorer.GetReservationPurchaseRecommendationInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetReservationPurchaseRecommendationOutput, error)
+	GetRightsizingRecommendation(ctx context.Context, params *costexplorer.GetRightsizingRecommendationInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetRightsizingRecommendationOutput, error)
+	GetDimensionValues(ctx context.Context, params *costexplorer.GetDimensionValuesInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetDimensionValuesOutput, error)
+	GetTags(ctx context.Context, params *costexplorer.GetTagsInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetTagsOutput, error)
 }
 
 type Client struct {
@@ -28,16 +129,7 @@ type Client struct {
 }
 
 func NewClient(ctx context.Context, profile string) (*Client, error) {
-	cfg, err := config.LoadDefaultConfig(ctx,
-		config.WithSharedConfigProfile(profile),
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	return &Client{
-		ce: costexplorer.NewFromConfig(cfg),
-	}, nil
+	return NewClientForTarget(ctx, Target{Profile: profile}, DefaultThrottle())
 }
 
 // NewClientWithAPI creates a client with a custom API (for testing)
@@ -45,60 +137,339 @@ func NewClientWithAPI(api CostExplorerAPI) *Client {
 	return &Client{ce: api}
 }
 
-func (c *Client) GetCostsByService(ctx context.Context, days int) ([]CostResult, error) {
-	end := time.Now()
-	start := end.AddDate(0, 0, -days)
-
-	input := &costexplorer.GetCostAndUsageInput{
-		TimePeriod: &types.DateInterval{
-			Start: aws.String(start.Format("2006-01-02")),
-			End:   aws.String(end.Format("2006-01-02")),
-		},
+// GetCostsByService returns monthly costs grouped by service for the last days
+// days, along with the number of Cost Explorer pages fetched.
+func (c *Client) GetCostsByService(ctx context.Context, days int) ([]CostResult, int, error) {
+	return c.GetCosts(ctx, CostQuery{
+		Period:      period.LastDays(days, time.Now()),
 		Granularity: types.GranularityMonthly,
-		Metrics:     []string{"UnblendedCost"},
-		GroupBy: []types.GroupDefinition{
-			{
-				Type: types.GroupDefinitionTypeDimension,
-				Key:  aws.String("SERVICE"),
-			},
-		},
+		GroupBy:     []GroupBy{ServiceGroupBy},
+	})
+}
+
+// GetCosts runs q and returns costs grouped by q.GroupBy, along with the
+// number of Cost Explorer pages fetched.
+func (c *Client) GetCosts(ctx context.Context, q CostQuery) ([]CostResult, int, error) {
+	output, pages, err := c.FetchCostAndUsage(ctx, q.Input())
+	if err != nil {
+		return nil, pages, err
+	}
+
+	return ParseCostResponse(output, q.metrics()...), pages, nil
+}
+
+// Input builds the GetCostAndUsage request for q
+func (q CostQuery) Input() *costexplorer.GetCostAndUsageInput {
+	granularity := q.Granularity
+	if granularity == "" {
+		granularity = types.GranularityMonthly
+	}
+
+	groupBy := q.GroupBy
+	if len(groupBy) == 0 {
+		groupBy = []GroupBy{ServiceGroupBy}
+	}
+	groups := make([]types.GroupDefinition, len(groupBy))
+	for i, g := range groupBy {
+		groups[i] = types.GroupDefinition{Type: g.Type, Key: aws.String(g.Key)}
+	}
+
+	return &costexplorer.GetCostAndUsageInput{
+		TimePeriod:  dateInterval(q.Period, granularity == types.GranularityHourly),
+		Granularity: granularity,
+		Metrics:     q.metrics(),
+		GroupBy:     groups,
+		Filter:      q.Filter,
+	}
+}
+
+// dateInterval converts r into a Cost Explorer interval. Hourly queries use
+// timestamps, since Cost Explorer rejects plain dates for that granularity.
+func dateInterval(r period.Range, hourly bool) *types.DateInterval {
+	layout := period.DateLayout
+	if hourly {
+		layout = "2006-01-02T15:04:05Z"
+	}
+	return &types.DateInterval{
+		Start: aws.String(r.Start.Format(layout)),
+		End:   aws.String(r.End.Format(layout)),
+	}
+}
+
+func (q CostQuery) metrics() []string {
+	if len(q.Metrics) == 0 {
+		return []string{DefaultMetric}
+	}
+	return q.Metrics
+}
+
+// ParseMetric converts a metric name such as "amortized", "net-unblended" or
+// "UsageQuantity" into its Cost Explorer name
+func ParseMetric(s string) (string, error) {
+	name := strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(strings.TrimSpace(s)))
+	for _, m := range Metrics {
+		lower := strings.ToLower(m)
+		if name == lower || name+"cost" == lower {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("unknown metric %q", s)
+}
+
+// ParseMetrics parses metric names, defaulting to DefaultMetric when none are
+// given
+func ParseMetrics(values []string) ([]string, error) {
+	if len(values) == 0 {
+		return []string{DefaultMetric}, nil
+	}
+	metrics := make([]string, len(values))
+	for i, v := range values {
+		m, err := ParseMetric(v)
+		if err != nil {
+			return nil, err
+		}
+		metrics[i] = m
+	}
+	return metrics, nil
+}
+
+// ParseGroupBy converts a dimension name such as "region", "usage-type" or
+// "LINKED_ACCOUNT" into a GroupBy. Cost allocation tags and cost categories
+// are given as "tag:<key>" and "costcategory:<name>".
+func ParseGroupBy(s string) (GroupBy, error) {
+	if prefix, name, ok := strings.Cut(s, ":"); ok {
+		if name == "" {
+			return GroupBy{}, fmt.Errorf("missing key in group-by %q", s)
+		}
+		switch strings.ToLower(prefix) {
+		case "tag":
+			return GroupBy{Type: types.GroupDefinitionTypeTag, Key: name}, nil
+		case "costcategory", "cost-category":
+			return GroupBy{Type: types.GroupDefinitionTypeCostCategory, Key: name}, nil
+		}
+		return GroupBy{}, fmt.Errorf("unknown group-by type %q", prefix)
 	}
 
-	output, err := c.ce.GetCostAndUsage(ctx, input)
+	d, err := ParseDimension(s)
 	if err != nil {
-		return nil, err
+		return GroupBy{}, fmt.Errorf("unknown group-by dimension %q", s)
+	}
+	return GroupBy{Type: types.GroupDefinitionTypeDimension, Key: string(d)}, nil
+}
+
+// ParseGroupBys parses up to MaxGroupBy groupings, defaulting to SERVICE when
+// none are given
+func ParseGroupBys(values []string) ([]GroupBy, error) {
+	if len(values) == 0 {
+		return []GroupBy{ServiceGroupBy}, nil
+	}
+	if len(values) > MaxGroupBy {
+		return nil, fmt.Errorf("at most %d group-by dimensions are supported, got %d", MaxGroupBy, len(values))
+	}
+	groupBy := make([]GroupBy, len(values))
+	for i, v := range values {
+		g, err := ParseGroupBy(v)
+		if err != nil {
+			return nil, err
+		}
+		groupBy[i] = g
+	}
+	return groupBy, nil
+}
+
+// ParseGranularity converts a granularity name (daily, monthly, hourly) into
+// its Cost Explorer value
+func ParseGranularity(s string) (types.Granularity, error) {
+	switch strings.ToLower(s) {
+	case "daily":
+		return types.GranularityDaily, nil
+	case "monthly", "":
+		return types.GranularityMonthly, nil
+	case "hourly":
+		return types.GranularityHourly, nil
+	}
+	return "", fmt.Errorf("unknown granularity %q (want daily, monthly or hourly)", s)
+}
+
+// FetchCostAndUsage follows NextPageToken until every page has been fetched and
+// merges the groups of matching time periods into a single output. It returns
+// the merged output and the number of pages fetched.
+func (c *Client) FetchCostAndUsage(ctx context.Context, input *costexplorer.GetCostAndUsageInput) (*costexplorer.GetCostAndUsageOutput, int, error) {
+	merged := &costexplorer.GetCostAndUsageOutput{}
+	index := make(map[string]int)
+	pages := 0
+	var token *string
+
+	for {
+		params := *input
+		params.NextPageToken = token
+		output, err := c.ce.GetCostAndUsage(ctx, &params)
+		if err != nil {
+			return nil, pages, fmt.Errorf("page %d: %w", pages+1, err)
+		}
+		pages++
+
+		merged.DimensionValueAttributes = append(merged.DimensionValueAttributes, output.DimensionValueAttributes...)
+		if merged.GroupDefinitions == nil {
+			merged.GroupDefinitions = output.GroupDefinitions
+		}
+
+		for _, result := range output.ResultsByTime {
+			key := periodKey(result.TimePeriod)
+			if i, ok := index[key]; ok {
+				merged.ResultsByTime[i].Groups = append(merged.ResultsByTime[i].Groups, result.Groups...)
+				continue
+			}
+			index[key] = len(merged.ResultsByTime)
+			merged.ResultsByTime = append(merged.ResultsByTime, result)
+		}
+
+		if aws.ToString(output.NextPageToken) == "" {
+			break
+		}
+		token = output.NextPageToken
 	}
 
-	return ParseCostResponse(output), nil
+	return merged, pages, nil
 }
 
-// ParseCostResponse parses AWS cost response into CostResults
-func ParseCostResponse(output *costexplorer.GetCostAndUsageOutput) []CostResult {
+func periodKey(period *types.DateInterval) string {
+	if period == nil {
+		return ""
+	}
+	return aws.ToString(period.Start) + "/" + aws.ToString(period.End)
+}
+
+// ParseCostResponse parses AWS cost response into CostResults. Groups with the
+// same keys are merged across time buckets, and each result keeps its
+// per-bucket amounts in Periods (zero for buckets where it had no cost).
+// metrics defaults to DefaultMetric; the first one drives Amount and sorting.
+func ParseCostResponse(output *costexplorer.GetCostAndUsageOutput, metrics ...string) []CostResult {
+	if len(metrics) == 0 {
+		metrics = []string{DefaultMetric}
+	}
+
 	var results []CostResult
-	for _, result := range output.ResultsByTime {
+	index := make(map[string]int)
+
+	for p, result := range output.ResultsByTime {
 		for _, group := range result.Groups {
-			if len(group.Keys) > 0 {
-				cost := group.Metrics["UnblendedCost"]
-				amount, _ := strconv.ParseFloat(aws.ToString(cost.Amount), 64)
+			if len(group.Keys) == 0 {
+				continue
+			}
+			cost := group.Metrics[metrics[0]]
+			amount, _ := strconv.ParseFloat(aws.ToString(cost.Amount), 64)
+
+			keys := groupKeys(output.GroupDefinitions, group.Keys)
+			key := strings.Join(keys, "\x00")
+			i, ok := index[key]
+			if !ok {
+				i = len(results)
+				index[key] = i
 				results = append(results, CostResult{
-					Service: group.Keys[0],
-					Amount:  amount,
+					Keys:    keys,
 					Unit:    aws.ToString(cost.Unit),
+					Periods: newPeriods(output.ResultsByTime, metrics),
 				})
+				if len(metrics) > 1 {
+					results[i].Metrics = make(map[string]float64, len(metrics))
+				}
+			}
+			results[i].Amount += amount
+			results[i].Periods[p].Amount += amount
+			if results[i].Unit == "" {
+				results[i].Unit = aws.ToString(cost.Unit)
+			}
+			if results[i].Metrics != nil {
+				for _, m := range metrics {
+					v, _ := strconv.ParseFloat(aws.ToString(group.Metrics[m].Amount), 64)
+					results[i].Metrics[m] += v
+					results[i].Periods[p].Metrics[m] += v
+				}
 			}
 		}
 	}
 	return SortByAmount(results)
 }
 
+// groupKeys strips the "key$" prefix Cost Explorer puts on tag and cost
+// category values, replacing empty values with Untagged
+func groupKeys(definitions []types.GroupDefinition, keys []string) []string {
+	out := make([]string, len(keys))
+	for i, k := range keys {
+		out[i] = k
+		if i >= len(definitions) || definitions[i].Type == types.GroupDefinitionTypeDimension {
+			continue
+		}
+		if _, value, ok := strings.Cut(k, "$"); ok {
+			out[i] = value
+		}
+		if out[i] == "" {
+			out[i] = Untagged
+		}
+	}
+	return out
+}
+
+// newPeriods returns one zero-valued PeriodCost per time bucket, with a zero
+// for each metric when there are several
+func newPeriods(buckets []types.ResultByTime, metrics []string) []PeriodCost {
+	periods := make([]PeriodCost, len(buckets))
+	for i, b := range buckets {
+		if b.TimePeriod != nil {
+			periods[i].Start = aws.ToString(b.TimePeriod.Start)
+			periods[i].End = aws.ToString(b.TimePeriod.End)
+		}
+		if len(metrics) > 1 {
+			periods[i].Metrics = make(map[string]float64, len(metrics))
+			for _, m := range metrics {
+				periods[i].Metrics[m] = 0
+			}
+		}
+	}
+	return periods
+}
+
+// TimeSeries flattens the per-period amounts of results into long format,
+// ordered by period and then by the order of results
+func TimeSeries(results []CostResult) []TimeSeriesPoint {
+	var points []TimeSeriesPoint
+	if len(results) == 0 {
+		return points
+	}
+	for p := range results[0].Periods {
+		for _, r := range results {
+			if p >= len(r.Periods) {
+				continue
+			}
+			points = append(points, TimeSeriesPoint{
+				Start:   r.Periods[p].Start,
+				End:     r.Periods[p].End,
+				Account: r.Account,
+				Keys:    r.Keys,
+				Amount:  r.Periods[p].Amount,
+				Unit:    r.Unit,
+				Metrics: r.Periods[p].Metrics,
+			})
+		}
+	}
+	return points
+}
+
 // SortByAmount sorts results by amount descending
 func SortByAmount(results []CostResult) []CostResult {
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Amount > results[j].Amount
-	})
+	sortByAmount(results, func(r CostResult) float64 { return r.Amount })
 	return results
 }
 
+// sortByAmount sorts items by amount, highest first, keeping equal items in
+// their original order
+func sortByAmount[T any](items []T, amount func(T) float64) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return amount(items[i]) > amount(items[j])
+	})
+}
+
 // TotalCost calculates total cost from results
 func TotalCost(results []CostResult) float64 {
 	var total float64
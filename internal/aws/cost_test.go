@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"math"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
 	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
@@ -15,10 +18,108 @@ import (
 type mockCostExplorer struct {
 	output *costexplorer.GetCostAndUsageOutput
 	err    error
+
+	// pages, when set, are returned in order on successive calls
+	pages []*costexplorer.GetCostAndUsageOutput
+	calls []*costexplorer.GetCostAndUsageInput
+
+	forecast      *costexplorer.GetCostForecastOutput
+	forecastCalls []*costexplorer.GetCostForecastInput
+
+	anomalies    []*costexplorer.GetAnomaliesOutput
+	anomalyCalls []*costexplorer.GetAnomaliesInput
+
+	spUtilization  *costexplorer.GetSavingsPlansUtilizationOutput
+	spCoverage     []*costexplorer.GetSavingsPlansCoverageOutput
+	riUtilization  *costexplorer.GetReservationUtilizationOutput
+	riCoverage     *costexplorer.GetReservationCoverageOutput
+	spCoverageCall []*costexplorer.GetSavingsPlansCoverageInput
+
+	spRecommendation *costexplorer.GetSavingsPlansPurchaseRecommendationOutput
+	riRecommendation *costexplorer.GetReservationPurchaseRecommendationOutput
+	riRecommendCalls []*costexplorer.GetReservationPurchaseRecommendationInput
+
+	rightsizing      *costexplorer.GetRightsizingRecommendationOutput
+	rightsizingCalls []*costexplorer.GetRightsizingRecommendationInput
+
+	dimensionValues []*costexplorer.GetDimensionValuesOutput
+	dimensionCalls  []*costexplorer.GetDimensionValuesInput
+	tags            []*costexplorer.GetTagsOutput
+	tagCalls        []*costexplorer.GetTagsInput
 }
 
 func (m *mockCostExplorer) GetCostAndUsage(ctx context.Context, params *costexplorer.GetCostAndUsageInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetCostAndUsageOutput, error) {
-	return m.output, m.err
+	m.calls = append(m.calls, params)
+	if m.err != nil {
+		return nil, m.err
+	}
+	if len(m.pages) > 0 {
+		return m.pages[len(m.calls)-1], nil
+	}
+	return m.output, nil
+}
+
+func (m *mockCostExplorer) GetCostForecast(ctx context.Context, params *costexplorer.GetCostForecastInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetCostForecastOutput, error) {
+	m.forecastCalls = append(m.forecastCalls, params)
+	return m.forecast, m.err
+}
+
+func (m *mockCostExplorer) GetAnomalies(ctx context.Context, params *costexplorer.GetAnomaliesInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetAnomaliesOutput, error) {
+	m.anomalyCalls = append(m.anomalyCalls, params)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.anomalies[len(m.anomalyCalls)-1], nil
+}
+
+func (m *mockCostExplorer) GetSavingsPlansUtilization(ctx context.Context, params *costexplorer.GetSavingsPlansUtilizationInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetSavingsPlansUtilizationOutput, error) {
+	return m.spUtilization, m.err
+}
+
+func (m *mockCostExplorer) GetSavingsPlansCoverage(ctx context.Context, params *costexplorer.GetSavingsPlansCoverageInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetSavingsPlansCoverageOutput, error) {
+	m.spCoverageCall = append(m.spCoverageCall, params)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.spCoverage[len(m.spCoverageCall)-1], nil
+}
+
+func (m *mockCostExplorer) GetReservationUtilization(ctx context.Context, params *costexplorer.GetReservationUtilizationInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetReservationUtilizationOutput, error) {
+	return m.riUtilization, m.err
+}
+
+func (m *mockCostExplorer) GetReservationCoverage(ctx context.Context, params *costexplorer.GetReservationCoverageInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetReservationCoverageOutput, error) {
+	return m.riCoverage, m.err
+}
+
+func (m *mockCostExplorer) GetSavingsPlansPurchaseRecommendation(ctx context.Context, params *costexplorer.GetSavingsPlansPurchaseRecommendationInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetSavingsPlansPurchaseRecommendationOutput, error) {
+	return m.spRecommendation, m.err
+}
+
+func (m *mockCostExplorer) GetReservationPurchaseRecommendation(ctx context.Context, params *costexplorer.GetReservationPurchaseRecommendationInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetReservationPurchaseRecommendationOutput, error) {
+	m.riRecommendCalls = append(m.riRecommendCalls, params)
+	return m.riRecommendation, m.err
+}
+
+func (m *mockCostExplorer) GetRightsizingRecommendation(ctx context.Context, params *costexplorer.GetRightsizingRecommendationInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetRightsizingRecommendationOutput, error) {
+	m.rightsizingCalls = append(m.rightsizingCalls, params)
+	return m.rightsizing, m.err
+}
+
+func (m *mockCostExplorer) GetDimensionValues(ctx context.Context, params *costexplorer.GetDimensionValuesInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetDimensionValuesOutput, error) {
+	m.dimensionCalls = append(m.dimensionCalls, params)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.dimensionValues[len(m.dimensionCalls)-1], nil
+}
+
+func (m *mockCostExplorer) GetTags(ctx context.Context, params *costexplorer.GetTagsInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetTagsOutput, error) {
+	m.tagCalls = append(m.tagCalls, params)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.tags[len(m.tagCalls)-1], nil
 }
 
 func TestSortByAmount(t *testing.T) {
@@ -35,51 +136,51 @@ func TestSortByAmount(t *testing.T) {
 		{
 			name: "single item",
 			input: []CostResult{
-				{Service: "EC2", Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"EC2"}, Amount: 100.0, Unit: "USD"},
 			},
 			expected: []CostResult{
-				{Service: "EC2", Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"EC2"}, Amount: 100.0, Unit: "USD"},
 			},
 		},
 		{
 			name: "already sorted",
 			input: []CostResult{
-				{Service: "EC2", Amount: 100.0, Unit: "USD"},
-				{Service: "S3", Amount: 50.0, Unit: "USD"},
-				{Service: "Lambda", Amount: 10.0, Unit: "USD"},
+				{Keys: []string{"EC2"}, Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"S3"}, Amount: 50.0, Unit: "USD"},
+				{Keys: []string{"Lambda"}, Amount: 10.0, Unit: "USD"},
 			},
 			expected: []CostResult{
-				{Service: "EC2", Amount: 100.0, Unit: "USD"},
-				{Service: "S3", Amount: 50.0, Unit: "USD"},
-				{Service: "Lambda", Amount: 10.0, Unit: "USD"},
+				{Keys: []string{"EC2"}, Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"S3"}, Amount: 50.0, Unit: "USD"},
+				{Keys: []string{"Lambda"}, Amount: 10.0, Unit: "USD"},
 			},
 		},
 		{
 			name: "reverse order",
 			input: []CostResult{
-				{Service: "Lambda", Amount: 10.0, Unit: "USD"},
-				{Service: "S3", Amount: 50.0, Unit: "USD"},
-				{Service: "EC2", Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"Lambda"}, Amount: 10.0, Unit: "USD"},
+				{Keys: []string{"S3"}, Amount: 50.0, Unit: "USD"},
+				{Keys: []string{"EC2"}, Amount: 100.0, Unit: "USD"},
 			},
 			expected: []CostResult{
-				{Service: "EC2", Amount: 100.0, Unit: "USD"},
-				{Service: "S3", Amount: 50.0, Unit: "USD"},
-				{Service: "Lambda", Amount: 10.0, Unit: "USD"},
+				{Keys: []string{"EC2"}, Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"S3"}, Amount: 50.0, Unit: "USD"},
+				{Keys: []string{"Lambda"}, Amount: 10.0, Unit: "USD"},
 			},
 		},
 		{
 			name: "mixed order",
 			input: []CostResult{
-				{Service: "S3", Amount: 50.0, Unit: "USD"},
-				{Service: "Lambda", Amount: 10.0, Unit: "USD"},
-				{Service: "EC2", Amount: 100.0, Unit: "USD"},
-				{Service: "RDS", Amount: 75.0, Unit: "USD"},
+				{Keys: []string{"S3"}, Amount: 50.0, Unit: "USD"},
+				{Keys: []string{"Lambda"}, Amount: 10.0, Unit: "USD"},
+				{Keys: []string{"EC2"}, Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"RDS"}, Amount: 75.0, Unit: "USD"},
 			},
 			expected: []CostResult{
-				{Service: "EC2", Amount: 100.0, Unit: "USD"},
-				{Service: "RDS", Amount: 75.0, Unit: "USD"},
-				{Service: "S3", Amount: 50.0, Unit: "USD"},
-				{Service: "Lambda", Amount: 10.0, Unit: "USD"},
+				{Keys: []string{"EC2"}, Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"RDS"}, Amount: 75.0, Unit: "USD"},
+				{Keys: []string{"S3"}, Amount: 50.0, Unit: "USD"},
+				{Keys: []string{"Lambda"}, Amount: 10.0, Unit: "USD"},
 			},
 		},
 	}
@@ -92,8 +193,8 @@ func TestSortByAmount(t *testing.T) {
 				return
 			}
 			for i := range result {
-				if result[i].Service != tt.expected[i].Service {
-					t.Errorf("index %d: service got %s, want %s", i, result[i].Service, tt.expected[i].Service)
+				if !reflect.DeepEqual(result[i].Keys, tt.expected[i].Keys) {
+					t.Errorf("index %d: keys got %v, want %v", i, result[i].Keys, tt.expected[i].Keys)
 				}
 				if result[i].Amount != tt.expected[i].Amount {
 					t.Errorf("index %d: amount got %f, want %f", i, result[i].Amount, tt.expected[i].Amount)
@@ -117,25 +218,25 @@ func TestTotalCost(t *testing.T) {
 		{
 			name: "single item",
 			input: []CostResult{
-				{Service: "EC2", Amount: 100.50, Unit: "USD"},
+				{Keys: []string{"EC2"}, Amount: 100.50, Unit: "USD"},
 			},
 			expected: 100.50,
 		},
 		{
 			name: "multiple items",
 			input: []CostResult{
-				{Service: "EC2", Amount: 100.0, Unit: "USD"},
-				{Service: "S3", Amount: 50.25, Unit: "USD"},
-				{Service: "Lambda", Amount: 10.75, Unit: "USD"},
+				{Keys: []string{"EC2"}, Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"S3"}, Amount: 50.25, Unit: "USD"},
+				{Keys: []string{"Lambda"}, Amount: 10.75, Unit: "USD"},
 			},
 			expected: 161.0,
 		},
 		{
 			name: "with zero amounts",
 			input: []CostResult{
-				{Service: "EC2", Amount: 100.0, Unit: "USD"},
-				{Service: "S3", Amount: 0.0, Unit: "USD"},
-				{Service: "Lambda", Amount: 50.0, Unit: "USD"},
+				{Keys: []string{"EC2"}, Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"S3"}, Amount: 0.0, Unit: "USD"},
+				{Keys: []string{"Lambda"}, Amount: 50.0, Unit: "USD"},
 			},
 			expected: 150.0,
 		},
@@ -184,7 +285,7 @@ func TestParseCostResponse(t *testing.T) {
 				},
 			},
 			expected: []CostResult{
-				{Service: "Amazon EC2", Amount: 150.75, Unit: "USD"},
+				{Keys: []string{"Amazon EC2"}, Amount: 150.75, Unit: "USD"},
 			},
 		},
 		{
@@ -216,9 +317,9 @@ func TestParseCostResponse(t *testing.T) {
 				},
 			},
 			expected: []CostResult{
-				{Service: "Amazon EC2", Amount: 200.00, Unit: "USD"},
-				{Service: "Amazon S3", Amount: 50.00, Unit: "USD"},
-				{Service: "AWS Lambda", Amount: 25.00, Unit: "USD"},
+				{Keys: []string{"Amazon EC2"}, Amount: 200.00, Unit: "USD"},
+				{Keys: []string{"Amazon S3"}, Amount: 50.00, Unit: "USD"},
+				{Keys: []string{"AWS Lambda"}, Amount: 25.00, Unit: "USD"},
 			},
 		},
 	}
@@ -231,8 +332,8 @@ func TestParseCostResponse(t *testing.T) {
 				return
 			}
 			for i := range result {
-				if result[i].Service != tt.expected[i].Service {
-					t.Errorf("index %d: service got %s, want %s", i, result[i].Service, tt.expected[i].Service)
+				if !reflect.DeepEqual(result[i].Keys, tt.expected[i].Keys) {
+					t.Errorf("index %d: keys got %v, want %v", i, result[i].Keys, tt.expected[i].Keys)
 				}
 				if math.Abs(result[i].Amount-tt.expected[i].Amount) > 0.001 {
 					t.Errorf("index %d: amount got %f, want %f", i, result[i].Amount, tt.expected[i].Amount)
@@ -245,6 +346,57 @@ func TestParseCostResponse(t *testing.T) {
 	}
 }
 
+func TestParseCostResponsePeriods(t *testing.T) {
+	metrics := func(amount string) map[string]types.MetricValue {
+		return map[string]types.MetricValue{
+			"UnblendedCost": {Amount: aws.String(amount), Unit: aws.String("USD")},
+		}
+	}
+	input := &costexplorer.GetCostAndUsageOutput{
+		ResultsByTime: []types.ResultByTime{
+			{
+				TimePeriod: &types.DateInterval{Start: aws.String("2024-01-17"), End: aws.String("2024-02-01")},
+				Groups: []types.Group{
+					{Keys: []string{"Amazon EC2"}, Metrics: metrics("10.00")},
+				},
+			},
+			{
+				TimePeriod: &types.DateInterval{Start: aws.String("2024-02-01"), End: aws.String("2024-03-01")},
+				Groups: []types.Group{
+					{Keys: []string{"Amazon EC2"}, Metrics: metrics("20.00")},
+					{Keys: []string{"Amazon S3"}, Metrics: metrics("5.00")},
+				},
+			},
+		},
+	}
+
+	results := ParseCostResponse(input)
+	if len(results) != 2 {
+		t.Fatalf("length: got %d, want 2", len(results))
+	}
+
+	expected := map[string][]float64{
+		"Amazon EC2": {10.00, 20.00},
+		"Amazon S3":  {0, 5.00},
+	}
+	for _, r := range results {
+		service := r.Keys[0]
+		want := expected[service]
+		if len(r.Periods) != len(want) {
+			t.Errorf("%s periods: got %d, want %d", service, len(r.Periods), len(want))
+			continue
+		}
+		for i, p := range r.Periods {
+			if math.Abs(p.Amount-want[i]) > 0.001 {
+				t.Errorf("%s period %d: got %f, want %f", service, i, p.Amount, want[i])
+			}
+		}
+		if r.Periods[1].Start != "2024-02-01" || r.Periods[1].End != "2024-03-01" {
+			t.Errorf("%s period 1: got %s..%s", service, r.Periods[1].Start, r.Periods[1].End)
+		}
+	}
+}
+
 func TestGetCostsByService(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -312,7 +464,7 @@ func TestGetCostsByService(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			client := NewClientWithAPI(tt.mock)
-			results, err := client.GetCostsByService(context.Background(), tt.days)
+			results, _, err := client.GetCostsByService(context.Background(), tt.days)
 
 			if tt.wantErr {
 				if err == nil {
@@ -332,8 +484,8 @@ func TestGetCostsByService(t *testing.T) {
 			}
 
 			if tt.wantLen > 0 {
-				if results[0].Service != tt.wantFirst {
-					t.Errorf("first service: got %s, want %s", results[0].Service, tt.wantFirst)
+				if results[0].Keys[0] != tt.wantFirst {
+					t.Errorf("first service: got %s, want %s", results[0].Keys[0], tt.wantFirst)
 				}
 				if math.Abs(results[0].Amount-tt.wantFirstAmt) > 0.001 {
 					t.Errorf("first amount: got %f, want %f", results[0].Amount, tt.wantFirstAmt)
@@ -343,6 +495,99 @@ func TestGetCostsByService(t *testing.T) {
 	}
 }
 
+func TestFetchCostAndUsagePagination(t *testing.T) {
+	period := &types.DateInterval{Start: aws.String("2024-01-01"), End: aws.String("2024-02-01")}
+	group := func(service, amount string) types.Group {
+		return types.Group{
+			Keys: []string{service},
+			Metrics: map[string]types.MetricValue{
+				"UnblendedCost": {Amount: aws.String(amount), Unit: aws.String("USD")},
+			},
+		}
+	}
+
+	mock := &mockCostExplorer{
+		pages: []*costexplorer.GetCostAndUsageOutput{
+			{
+				ResultsByTime: []types.ResultByTime{{TimePeriod: period, Groups: []types.Group{group("Amazon EC2", "100.00")}}},
+				NextPageToken: aws.String("page-2"),
+			},
+			{
+				ResultsByTime: []types.ResultByTime{{TimePeriod: period, Groups: []types.Group{group("Amazon S3", "50.00")}}},
+				NextPageToken: aws.String("page-3"),
+			},
+			{
+				ResultsByTime: []types.ResultByTime{{TimePeriod: period, Groups: []types.Group{group("AWS Lambda", "25.00")}}},
+			},
+		},
+	}
+
+	client := NewClientWithAPI(mock)
+	results, pages, err := client.GetCostsByService(context.Background(), 30)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if pages != 3 {
+		t.Errorf("pages: got %d, want 3", pages)
+	}
+	if len(mock.calls) != 3 {
+		t.Fatalf("calls: got %d, want 3", len(mock.calls))
+	}
+	if mock.calls[0].NextPageToken != nil {
+		t.Errorf("first call token: got %q, want nil", aws.ToString(mock.calls[0].NextPageToken))
+	}
+	if got := aws.ToString(mock.calls[2].NextPageToken); got != "page-3" {
+		t.Errorf("third call token: got %q, want page-3", got)
+	}
+	if len(results) != 3 {
+		t.Fatalf("length: got %d, want 3", len(results))
+	}
+	if math.Abs(TotalCost(results)-175.0) > 0.001 {
+		t.Errorf("total: got %f, want 175.00", TotalCost(results))
+	}
+}
+
+func TestFetchCostAndUsageMergesPeriods(t *testing.T) {
+	jan := &types.DateInterval{Start: aws.String("2024-01-01"), End: aws.String("2024-02-01")}
+	feb := &types.DateInterval{Start: aws.String("2024-02-01"), End: aws.String("2024-03-01")}
+
+	mock := &mockCostExplorer{
+		pages: []*costexplorer.GetCostAndUsageOutput{
+			{
+				ResultsByTime: []types.ResultByTime{
+					{TimePeriod: jan, Groups: []types.Group{{Keys: []string{"Amazon EC2"}}}},
+					{TimePeriod: feb, Groups: []types.Group{{Keys: []string{"Amazon EC2"}}}},
+				},
+				NextPageToken: aws.String("next"),
+			},
+			{
+				ResultsByTime: []types.ResultByTime{
+					{TimePeriod: jan, Groups: []types.Group{{Keys: []string{"Amazon S3"}}}},
+					{TimePeriod: feb, Groups: []types.Group{{Keys: []string{"Amazon S3"}}}},
+				},
+			},
+		},
+	}
+
+	client := NewClientWithAPI(mock)
+	output, pages, err := client.FetchCostAndUsage(context.Background(), &costexplorer.GetCostAndUsageInput{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pages != 2 {
+		t.Errorf("pages: got %d, want 2", pages)
+	}
+	if len(output.ResultsByTime) != 2 {
+		t.Fatalf("periods: got %d, want 2", len(output.ResultsByTime))
+	}
+	for i, result := range output.ResultsByTime {
+		if len(result.Groups) != 2 {
+			t.Errorf("period %d groups: got %d, want 2", i, len(result.Groups))
+		}
+	}
+}
+
 func TestNewClientWithAPI(t *testing.T) {
 	mock := &mockCostExplorer{}
 	client := NewClientWithAPI(mock)
@@ -353,3 +598,436 @@ func TestNewClientWithAPI(t *testing.T) {
 		t.Error("client api not set correctly")
 	}
 }
+
+func TestParseGranularity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected types.Granularity
+		wantErr  bool
+	}{
+		{input: "daily", expected: types.GranularityDaily},
+		{input: "MONTHLY", expected: types.GranularityMonthly},
+		{input: "", expected: types.GranularityMonthly},
+		{input: "hourly", expected: types.GranularityHourly},
+		{input: "weekly", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := ParseGranularity(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("got %s, want %s", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCostQueryInput(t *testing.T) {
+	now := time.Date(2024, 3, 15, 10, 42, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		query     CostQuery
+		wantStart string
+		wantEnd   string
+		wantGran  types.Granularity
+	}{
+		{
+			name:      "default granularity",
+			query:     CostQuery{Period: period.LastDays(30, now)},
+			wantStart: "2024-02-14",
+			wantEnd:   "2024-03-15",
+			wantGran:  types.GranularityMonthly,
+		},
+		{
+			name:      "daily",
+			query:     CostQuery{Period: period.LastDays(7, now), Granularity: types.GranularityDaily},
+			wantStart: "2024-03-08",
+			wantEnd:   "2024-03-15",
+			wantGran:  types.GranularityDaily,
+		},
+		{
+			name:      "hourly uses timestamps",
+			query:     CostQuery{Period: period.LastDays(2, now), Granularity: types.GranularityHourly},
+			wantStart: "2024-03-13T00:00:00Z",
+			wantEnd:   "2024-03-15T00:00:00Z",
+			wantGran:  types.GranularityHourly,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := tt.query.Input()
+			if got := aws.ToString(input.TimePeriod.Start); got != tt.wantStart {
+				t.Errorf("start: got %s, want %s", got, tt.wantStart)
+			}
+			if got := aws.ToString(input.TimePeriod.End); got != tt.wantEnd {
+				t.Errorf("end: got %s, want %s", got, tt.wantEnd)
+			}
+			if input.Granularity != tt.wantGran {
+				t.Errorf("granularity: got %s, want %s", input.Granularity, tt.wantGran)
+			}
+		})
+	}
+}
+
+func TestTimeSeries(t *testing.T) {
+	results := []CostResult{
+		{
+			Keys:   []string{"Amazon EC2"},
+			Amount: 30.0,
+			Unit:   "USD",
+			Periods: []PeriodCost{
+				{Start: "2024-03-01", End: "2024-03-02", Amount: 10.0},
+				{Start: "2024-03-02", End: "2024-03-03", Amount: 20.0},
+			},
+		},
+		{
+			Keys:   []string{"Amazon S3"},
+			Amount: 5.0,
+			Unit:   "USD",
+			Periods: []PeriodCost{
+				{Start: "2024-03-01", End: "2024-03-02", Amount: 0},
+				{Start: "2024-03-02", End: "2024-03-03", Amount: 5.0},
+			},
+		},
+	}
+
+	expected := []TimeSeriesPoint{
+		{Start: "2024-03-01", End: "2024-03-02", Keys: []string{"Amazon EC2"}, Amount: 10.0, Unit: "USD"},
+		{Start: "2024-03-01", End: "2024-03-02", Keys: []string{"Amazon S3"}, Amount: 0, Unit: "USD"},
+		{Start: "2024-03-02", End: "2024-03-03", Keys: []string{"Amazon EC2"}, Amount: 20.0, Unit: "USD"},
+		{Start: "2024-03-02", End: "2024-03-03", Keys: []string{"Amazon S3"}, Amount: 5.0, Unit: "USD"},
+	}
+
+	points := TimeSeries(results)
+	if len(points) != len(expected) {
+		t.Fatalf("length: got %d, want %d", len(points), len(expected))
+	}
+	for i := range points {
+		if !reflect.DeepEqual(points[i], expected[i]) {
+			t.Errorf("index %d: got %+v, want %+v", i, points[i], expected[i])
+		}
+	}
+}
+
+func TestParseGroupBys(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []string
+		expected []GroupBy
+		wantErr  bool
+	}{
+		{
+			name:     "default to service",
+			input:    nil,
+			expected: []GroupBy{ServiceGroupBy},
+		},
+		{
+			name:  "dimension names",
+			input: []string{"LINKED_ACCOUNT", "region"},
+			expected: []GroupBy{
+				{Type: types.GroupDefinitionTypeDimension, Key: "LINKED_ACCOUNT"},
+				{Type: types.GroupDefinitionTypeDimension, Key: "REGION"},
+			},
+		},
+		{
+			name:  "aliases and dashes",
+			input: []string{"account", "usage-type"},
+			expected: []GroupBy{
+				{Type: types.GroupDefinitionTypeDimension, Key: "LINKED_ACCOUNT"},
+				{Type: types.GroupDefinitionTypeDimension, Key: "USAGE_TYPE"},
+			},
+		},
+		{
+			name:  "tag and cost category",
+			input: []string{"tag:team", "costcategory:BusinessUnit"},
+			expected: []GroupBy{
+				{Type: types.GroupDefinitionTypeTag, Key: "team"},
+				{Type: types.GroupDefinitionTypeCostCategory, Key: "BusinessUnit"},
+			},
+		},
+		{
+			name:    "unknown dimension",
+			input:   []string{"colour"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown prefix",
+			input:   []string{"label:team"},
+			wantErr: true,
+		},
+		{
+			name:    "missing tag key",
+			input:   []string{"tag:"},
+			wantErr: true,
+		},
+		{
+			name:    "too many",
+			input:   []string{"service", "region", "operation"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseGroupBys(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("got %+v, want %+v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetCostsGroupBy(t *testing.T) {
+	mock := &mockCostExplorer{
+		output: &costexplorer.GetCostAndUsageOutput{
+			ResultsByTime: []types.ResultByTime{
+				{
+					Groups: []types.Group{
+						{
+							Keys: []string{"111111111111", "us-east-1"},
+							Metrics: map[string]types.MetricValue{
+								"UnblendedCost": {Amount: aws.String("80.00"), Unit: aws.String("USD")},
+							},
+						},
+						{
+							Keys: []string{"111111111111", "eu-west-1"},
+							Metrics: map[string]types.MetricValue{
+								"UnblendedCost": {Amount: aws.String("20.00"), Unit: aws.String("USD")},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	groupBy, err := ParseGroupBys([]string{"account", "region"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	client := NewClientWithAPI(mock)
+	results, _, err := client.GetCosts(context.Background(), CostQuery{Period: period.LastDays(30, time.Now()), GroupBy: groupBy})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	input := mock.calls[0]
+	if len(input.GroupBy) != 2 {
+		t.Fatalf("group by: got %d, want 2", len(input.GroupBy))
+	}
+	if aws.ToString(input.GroupBy[0].Key) != "LINKED_ACCOUNT" || aws.ToString(input.GroupBy[1].Key) != "REGION" {
+		t.Errorf("group by keys: got %s, %s", aws.ToString(input.GroupBy[0].Key), aws.ToString(input.GroupBy[1].Key))
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("length: got %d, want 2", len(results))
+	}
+	if !reflect.DeepEqual(results[0].Keys, []string{"111111111111", "us-east-1"}) {
+		t.Errorf("first keys: got %v", results[0].Keys)
+	}
+}
+
+func TestParseCostResponseTags(t *testing.T) {
+	metrics := func(amount string) map[string]types.MetricValue {
+		return map[string]types.MetricValue{
+			"UnblendedCost": {Amount: aws.String(amount), Unit: aws.String("USD")},
+		}
+	}
+	input := &costexplorer.GetCostAndUsageOutput{
+		GroupDefinitions: []types.GroupDefinition{
+			{Type: types.GroupDefinitionTypeDimension, Key: aws.String("SERVICE")},
+			{Type: types.GroupDefinitionTypeTag, Key: aws.String("team")},
+		},
+		ResultsByTime: []types.ResultByTime{
+			{
+				Groups: []types.Group{
+					{Keys: []string{"Amazon EC2", "team$platform"}, Metrics: metrics("60.00")},
+					{Keys: []string{"Amazon EC2", "team$"}, Metrics: metrics("30.00")},
+					{Keys: []string{"Amazon S3", "team$data$lake"}, Metrics: metrics("10.00")},
+				},
+			},
+		},
+	}
+
+	expected := [][]string{
+		{"Amazon EC2", "platform"},
+		{"Amazon EC2", Untagged},
+		{"Amazon S3", "data$lake"},
+	}
+
+	results := ParseCostResponse(input)
+	if len(results) != len(expected) {
+		t.Fatalf("length: got %d, want %d", len(results), len(expected))
+	}
+	for i := range results {
+		if !reflect.DeepEqual(results[i].Keys, expected[i]) {
+			t.Errorf("index %d: keys got %v, want %v", i, results[i].Keys, expected[i])
+		}
+	}
+}
+
+func TestGroupByString(t *testing.T) {
+	tests := []struct {
+		input    GroupBy
+		expected string
+	}{
+		{input: ServiceGroupBy, expected: "service"},
+		{input: GroupBy{Type: types.GroupDefinitionTypeTag, Key: "team"}, expected: "tag:team"},
+		{input: GroupBy{Type: types.GroupDefinitionTypeCostCategory, Key: "BusinessUnit"}, expected: "costcategory:BusinessUnit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := tt.input.String(); got != tt.expected {
+				t.Errorf("got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseMetrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []string
+		expected []string
+		wantErr  bool
+	}{
+		{name: "default", input: nil, expected: []string{"UnblendedCost"}},
+		{name: "short names", input: []string{"amortized", "net-unblended"}, expected: []string{"AmortizedCost", "NetUnblendedCost"}},
+		{name: "cost explorer names", input: []string{"BlendedCost", "UsageQuantity"}, expected: []string{"BlendedCost", "UsageQuantity"}},
+		{name: "snake case", input: []string{"net_amortized", "normalized_usage_amount"}, expected: []string{"NetAmortizedCost", "NormalizedUsageAmount"}},
+		{name: "unknown", input: []string{"list-price"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseMetrics(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("got %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetCostsMultipleMetrics(t *testing.T) {
+	mock := &mockCostExplorer{
+		output: &costexplorer.GetCostAndUsageOutput{
+			ResultsByTime: []types.ResultByTime{
+				{
+					Groups: []types.Group{
+						{
+							Keys: []string{"Amazon EC2"},
+							Metrics: map[string]types.MetricValue{
+								"AmortizedCost": {Amount: aws.String("80.00"), Unit: aws.String("USD")},
+								"UnblendedCost": {Amount: aws.String("100.00"), Unit: aws.String("USD")},
+							},
+						},
+						{
+							Keys: []string{"Amazon S3"},
+							Metrics: map[string]types.MetricValue{
+								"AmortizedCost": {Amount: aws.String("90.00"), Unit: aws.String("USD")},
+								"UnblendedCost": {Amount: aws.String("40.00"), Unit: aws.String("USD")},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	client := NewClientWithAPI(mock)
+	results, _, err := client.GetCosts(context.Background(), CostQuery{
+		Period:  period.LastDays(30, time.Now()),
+		Metrics: []string{"AmortizedCost", "UnblendedCost"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(mock.calls[0].Metrics, []string{"AmortizedCost", "UnblendedCost"}) {
+		t.Errorf("metrics: got %v", mock.calls[0].Metrics)
+	}
+	if len(results) != 2 {
+		t.Fatalf("length: got %d, want 2", len(results))
+	}
+
+	// sorted by the first metric
+	if results[0].Keys[0] != "Amazon S3" || math.Abs(results[0].Amount-90.0) > 0.001 {
+		t.Errorf("first: got %v %f, want Amazon S3 90.00", results[0].Keys, results[0].Amount)
+	}
+	if math.Abs(results[0].Metrics["UnblendedCost"]-40.0) > 0.001 {
+		t.Errorf("unblended: got %f, want 40.00", results[0].Metrics["UnblendedCost"])
+	}
+	if math.Abs(results[1].Metrics["AmortizedCost"]-80.0) > 0.001 {
+		t.Errorf("amortized: got %f, want 80.00", results[1].Metrics["AmortizedCost"])
+	}
+}
+
+func TestParseCostResponsePeriodMetrics(t *testing.T) {
+	metric := func(amortized, unblended string) map[string]types.MetricValue {
+		return map[string]types.MetricValue{
+			"AmortizedCost": {Amount: aws.String(amortized), Unit: aws.String("USD")},
+			"UnblendedCost": {Amount: aws.String(unblended), Unit: aws.String("USD")},
+		}
+	}
+	output := &costexplorer.GetCostAndUsageOutput{
+		ResultsByTime: []types.ResultByTime{
+			{
+				TimePeriod: &types.DateInterval{Start: aws.String("2024-03-01"), End: aws.String("2024-03-02")},
+				Groups:     []types.Group{{Keys: []string{"Amazon EC2"}, Metrics: metric("8", "10")}},
+			},
+			{
+				TimePeriod: &types.DateInterval{Start: aws.String("2024-03-02"), End: aws.String("2024-03-03")},
+				Groups:     []types.Group{{Keys: []string{"Amazon EC2"}, Metrics: metric("9", "12")}},
+			},
+		},
+	}
+
+	results := ParseCostResponse(output, "AmortizedCost", "UnblendedCost")
+	points := TimeSeries(results)
+	if len(points) != 2 {
+		t.Fatalf("length: got %d, want 2", len(points))
+	}
+	expected := []map[string]float64{
+		{"AmortizedCost": 8, "UnblendedCost": 10},
+		{"AmortizedCost": 9, "UnblendedCost": 12},
+	}
+	for i, p := range points {
+		if !reflect.DeepEqual(p.Metrics, expected[i]) {
+			t.Errorf("point %d metrics: got %v, want %v", i, p.Metrics, expected[i])
+		}
+		if p.Amount != expected[i]["AmortizedCost"] {
+			t.Errorf("point %d amount: got %.2f, want the first metric", i, p.Amount)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+// Package cur reads AWS Cost and Usage Report files that have been synced to
+// local disk and aggregates them into the same breakdowns as Cost Explorer.
+package cur
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/aws"
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+// LineItem is the part of a CUR line item the reports use. Service is the
+// product name when the report has one, otherwise the product code.
+type LineItem struct {
+	UsageStart     time.Time
+	Account        string
+	Service        string
+	Region         string
+	UsageType      string
+	Operation      string
+	ResourceID     string
+	LineItemType   string
+	Cost           float64
+	Currency       string
+	Tags           map[string]string
+	CostCategories map[string]string
+}
+
+// Query aggregates line items within Period into groups. An empty
+// LineItemTypes keeps every line item type.
+type Query struct {
+	Period        period.Range
+	Granularity   types.Granularity
+	GroupBy       []aws.GroupBy
+	LineItemTypes []string
+	Include       []aws.Filter
+	Exclude       []aws.Filter
+}
+
+// Validate checks that every group and filter can be answered from CUR
+// columns
+func (q Query) Validate() error {
+	check := func(g aws.GroupBy) error {
+		_, err := (LineItem{}).value(g)
+		return err
+	}
+	for _, g := range q.groupBy() {
+		if err := check(g); err != nil {
+			return err
+		}
+	}
+	for _, f := range append(append([]aws.Filter(nil), q.Include...), q.Exclude...) {
+		if err := check(f.GroupBy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q Query) groupBy() []aws.GroupBy {
+	if len(q.GroupBy) == 0 {
+		return []aws.GroupBy{aws.ServiceGroupBy}
+	}
+	return q.GroupBy
+}
+
+// Report reads every CUR file under path, which may be a single file, a
+// directory or a manifest, and aggregates it by q. Results are sorted by
+// amount, highest first.
+func Report(path string, q Query) ([]aws.CostResult, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	files, err := Files(path)
+	if err != nil {
+		return nil, err
+	}
+
+	agg := newAggregator(q)
+	for _, file := range files {
+		if err := Scan(file, agg.add); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return agg.results(), nil
+}
+
+// value returns the value of item for g. Groups that CUR has no column for
+// return an error.
+func (item LineItem) value(g aws.GroupBy) (string, error) {
+	switch g.Type {
+	case types.GroupDefinitionTypeTag:
+		return item.Tags[g.Key], nil
+	case types.GroupDefinitionTypeCostCategory:
+		return item.CostCategories[g.Key], nil
+	}
+
+	switch types.Dimension(g.Key) {
+	case types.DimensionService:
+		return item.Service, nil
+	case types.DimensionLinkedAccount:
+		return item.Account, nil
+	case types.DimensionRegion:
+		return item.Region, nil
+	case types.DimensionUsageType:
+		return item.UsageType, nil
+	case types.DimensionOperation:
+		return item.Operation, nil
+	case types.DimensionResourceId:
+		return item.ResourceID, nil
+	case types.DimensionRecordType:
+		return item.LineItemType, nil
+	}
+	return "", fmt.Errorf("%s is not available from cost and usage reports", g)
+}
+
+// matches reports whether item passes the line item type and filter
+// conditions of q
+func (q Query) matches(item LineItem) bool {
+	if !q.Period.Start.IsZero() && !q.Period.Contains(item.UsageStart) {
+		return false
+	}
+	if len(q.LineItemTypes) > 0 && !containsFold(q.LineItemTypes, item.LineItemType) {
+		return false
+	}
+	for _, f := range q.Include {
+		if !filterMatches(f, item) {
+			return false
+		}
+	}
+	for _, f := range q.Exclude {
+		if filterMatches(f, item) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterMatches reports whether item has one of the filter values, or no value
+// at all when the filter lists none
+func filterMatches(f aws.Filter, item LineItem) bool {
+	value, _ := item.value(f.GroupBy)
+	if len(f.Values) == 0 {
+		return value == ""
+	}
+	for _, v := range f.Values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+type aggregator struct {
+	q       Query
+	groups  map[string]*aws.CostResult
+	periods map[string]map[time.Time]float64
+}
+
+func newAggregator(q Query) *aggregator {
+	return &aggregator{
+		q:       q,
+		groups:  make(map[string]*aws.CostResult),
+		periods: make(map[string]map[time.Time]float64),
+	}
+}
+
+func (a *aggregator) add(item LineItem) error {
+	if !a.q.matches(item) {
+		return nil
+	}
+
+	keys := make([]string, 0, len(a.q.groupBy()))
+	for _, g := range a.q.groupBy() {
+		value, _ := item.value(g)
+		if value == "" && g.Type != types.GroupDefinitionTypeDimension {
+			value = aws.Untagged
+		}
+		keys = append(keys, value)
+	}
+	id := strings.Join(keys, "|")
+
+	result, ok := a.groups[id]
+	if !ok {
+		result = &aws.CostResult{Keys: keys, Unit: item.Currency}
+		a.groups[id] = result
+		a.periods[id] = make(map[time.Time]float64)
+	}
+	result.Amount += item.Cost
+	a.periods[id][bucketStart(item.UsageStart, a.q.Granularity)] += item.Cost
+	return nil
+}
+
+func (a *aggregator) results() []aws.CostResult {
+	starts := a.buckets()
+	results := make([]aws.CostResult, 0, len(a.groups))
+	for id, result := range a.groups {
+		// every group gets every bucket, like Cost Explorer, so series line up
+		result.Periods = make([]aws.PeriodCost, len(starts))
+		for i, start := range starts {
+			result.Periods[i] = a.periodCost(start, a.periods[id][start])
+		}
+		results = append(results, *result)
+	}
+
+	// map iteration order is random, so order ties by key for stable output
+	sort.Slice(results, func(i, j int) bool {
+		return strings.Join(results[i].Keys, "|") < strings.Join(results[j].Keys, "|")
+	})
+	return aws.SortByAmount(results)
+}
+
+// buckets returns the start of every bucket in the query period, or between
+// the first and last bucket with data when the query has no period
+func (a *aggregator) buckets() []time.Time {
+	var first, last time.Time
+	if !a.q.Period.Start.IsZero() {
+		first = bucketStart(a.q.Period.Start, a.q.Granularity)
+		last = bucketStart(a.q.Period.End.Add(-time.Nanosecond), a.q.Granularity)
+	} else {
+		for _, periods := range a.periods {
+			for start := range periods {
+				if first.IsZero() || start.Before(first) {
+					first = start
+				}
+				if start.After(last) {
+					last = start
+				}
+			}
+		}
+		if first.IsZero() {
+			return nil
+		}
+	}
+
+	var starts []time.Time
+	for start := first; !start.After(last); start = bucketEnd(start, a.q.Granularity) {
+		starts = append(starts, start)
+	}
+	return starts
+}
+
+// periodCost returns the bucket starting at start, clipped to the query
+// period like Cost Explorer clips its first and last buckets
+func (a *aggregator) periodCost(start time.Time, amount float64) aws.PeriodCost {
+	end := bucketEnd(start, a.q.Granularity)
+	if !a.q.Period.Start.IsZero() {
+		if start.Before(a.q.Period.Start) {
+			start = a.q.Period.Start
+		}
+		if end.After(a.q.Period.End) {
+			end = a.q.Period.End
+		}
+	}
+
+	layout := period.DateLayout
+	if a.q.Granularity == types.GranularityHourly {
+		layout = time.RFC3339
+	}
+	return aws.PeriodCost{Start: start.Format(layout), End: end.Format(layout), Amount: amount}
+}
+
+func bucketStart(t time.Time, g types.Granularity) time.Time {
+	t = t.UTC()
+	switch g {
+	case types.GranularityHourly:
+		return t.Truncate(time.Hour)
+	case types.GranularityDaily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func bucketEnd(start time.Time, g types.Granularity) time.Time {
+	switch g {
+	case types.GranularityHourly:
+		return start.Add(time.Hour)
+	case types.GranularityDaily:
+		return start.AddDate(0, 0, 1)
+	}
+	return start.AddDate(0, 1, 0)
+}
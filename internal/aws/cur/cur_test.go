@@ -0,0 +1,140 @@
+package cur
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/aws"
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+func march(day int) time.Time {
+	return time.Date(2024, 3, day, 0, 0, 0, 0, time.UTC)
+}
+
+func aggregate(t *testing.T, q Query, items []LineItem) []aws.CostResult {
+	t.Helper()
+	if err := q.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	agg := newAggregator(q)
+	for _, item := range items {
+		agg.add(item)
+	}
+	return agg.results()
+}
+
+var testItems = []LineItem{
+	{UsageStart: march(1), Service: "AmazonEC2", ResourceID: "i-1", LineItemType: "Usage", Cost: 10, Currency: "USD", Tags: map[string]string{"env": "prod"}},
+	{UsageStart: march(2), Service: "AmazonEC2", ResourceID: "i-2", LineItemType: "Usage", Cost: 4, Currency: "USD"},
+	{UsageStart: march(2), Service: "AmazonEC2", LineItemType: "Tax", Cost: 1.4, Currency: "USD"},
+	{UsageStart: march(3), Service: "AmazonS3", ResourceID: "bucket", LineItemType: "Usage", Cost: 6, Currency: "USD", Tags: map[string]string{"env": "dev"}},
+	{UsageStart: march(3), Service: "AmazonEC2", LineItemType: "Credit", Cost: -2, Currency: "USD"},
+}
+
+func TestAggregateByService(t *testing.T) {
+	results := aggregate(t, Query{Granularity: types.GranularityDaily}, testItems)
+
+	if len(results) != 2 {
+		t.Fatalf("length: got %d, want 2", len(results))
+	}
+	if results[0].Keys[0] != "AmazonEC2" || results[0].Amount != 13.4 {
+		t.Errorf("first: got %+v", results[0])
+	}
+	if len(results[0].Periods) != 3 || results[0].Periods[1].Start != "2024-03-02" || results[0].Periods[1].Amount != 5.4 {
+		t.Errorf("periods: got %+v", results[0].Periods)
+	}
+}
+
+func TestAggregateByResource(t *testing.T) {
+	q := Query{
+		GroupBy:       []aws.GroupBy{{Type: types.GroupDefinitionTypeDimension, Key: "RESOURCE_ID"}},
+		LineItemTypes: []string{"usage"},
+	}
+	results := aggregate(t, q, testItems)
+
+	if len(results) != 3 {
+		t.Fatalf("length: got %d, want 3", len(results))
+	}
+	expected := []string{"i-1", "bucket", "i-2"}
+	for i, id := range expected {
+		if results[i].Keys[0] != id {
+			t.Errorf("index %d: got %s, want %s", i, results[i].Keys[0], id)
+		}
+	}
+}
+
+func TestAggregateFilters(t *testing.T) {
+	q := Query{
+		Period:  period.Range{Start: march(1), End: march(3)},
+		GroupBy: []aws.GroupBy{{Type: types.GroupDefinitionTypeTag, Key: "env"}},
+		Exclude: []aws.Filter{{GroupBy: aws.GroupBy{Type: types.GroupDefinitionTypeDimension, Key: "RECORD_TYPE"}, Values: []string{"Tax"}}},
+	}
+	results := aggregate(t, q, testItems)
+
+	if len(results) != 2 {
+		t.Fatalf("length: got %d, want 2", len(results))
+	}
+	if results[0].Keys[0] != "prod" || results[0].Amount != 10 {
+		t.Errorf("first: got %+v", results[0])
+	}
+	if results[1].Keys[0] != aws.Untagged || results[1].Amount != 4 {
+		t.Errorf("second: got %+v", results[1])
+	}
+	if len(results[0].Periods) != 1 || results[0].Periods[0].Start != "2024-03-01" || results[0].Periods[0].End != "2024-03-03" {
+		t.Errorf("clipped period: got %+v", results[0].Periods)
+	}
+}
+
+func TestAggregateDenseBuckets(t *testing.T) {
+	q := Query{Period: period.Range{Start: march(1), End: march(4)}, Granularity: types.GranularityDaily}
+	items := []LineItem{
+		{UsageStart: march(1), Service: "AmazonEC2", Cost: 10, Currency: "USD"},
+		{UsageStart: march(2), Service: "AmazonS3", Cost: 2, Currency: "USD"},
+		{UsageStart: march(3), Service: "AmazonS3", Cost: 3, Currency: "USD"},
+	}
+	results := aggregate(t, q, items)
+
+	expected := map[string][]float64{"AmazonEC2": {10, 0, 0}, "AmazonS3": {0, 2, 3}}
+	for _, r := range results {
+		want := expected[r.Keys[0]]
+		if len(r.Periods) != len(want) {
+			t.Fatalf("%s periods: got %+v, want %d buckets", r.Keys[0], r.Periods, len(want))
+		}
+		for i, p := range r.Periods {
+			if p.Start != march(i+1).Format(period.DateLayout) || p.Amount != want[i] {
+				t.Errorf("%s bucket %d: got %+v, want %s %.0f", r.Keys[0], i, p, march(i+1).Format(period.DateLayout), want[i])
+			}
+		}
+	}
+
+	series := aws.TimeSeries(results)
+	if len(series) != 6 {
+		t.Errorf("series: got %d points, want 6", len(series))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	q := Query{GroupBy: []aws.GroupBy{{Type: types.GroupDefinitionTypeDimension, Key: "INSTANCE_TYPE"}}}
+	if err := q.Validate(); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestReport(t *testing.T) {
+	dir := t.TempDir()
+	writeParquet(t, filepath.Join(dir, "part-0.parquet"), []parquetRow{
+		{Type: "Usage", Start: march(1), Product: "AmazonEC2", Cost: 2, Currency: "USD"},
+		{Type: "Usage", Start: march(5), Product: "AmazonEC2", Cost: 3, Currency: "USD"},
+	})
+
+	results, err := Report(dir, Query{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Amount != 5 || results[0].Unit != "USD" {
+		t.Errorf("results: got %+v", results)
+	}
+}
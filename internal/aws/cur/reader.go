@@ -0,0 +1,417 @@
+package cur
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+// Files returns the CUR data files at path. A directory is searched
+// recursively for .csv, .csv.gz and .parquet files. A .json file is read as a
+// report manifest, which is the safer choice for legacy reports that keep
+// superseded versions of a month next to the current one.
+func Files(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		if strings.HasSuffix(strings.ToLower(path), ".json") {
+			return manifestFiles(path)
+		}
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && isDataFile(p) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no cost and usage report files found in %s", path)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func isDataFile(path string) bool {
+	name := strings.ToLower(path)
+	return strings.HasSuffix(name, ".csv") || strings.HasSuffix(name, ".csv.gz") || strings.HasSuffix(name, ".parquet")
+}
+
+// manifest holds the data file keys of a legacy CUR manifest (reportKeys) or
+// a CUR 2.0 data export manifest (dataFiles)
+type manifest struct {
+	ReportKeys []string `json:"reportKeys"`
+	DataFiles  []string `json:"dataFiles"`
+}
+
+// manifestFiles resolves the keys listed in a manifest against the folder the
+// manifest was synced into. Keys are S3 paths, so leading path segments are
+// dropped until the remainder exists locally.
+func manifestFiles(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+
+	keys := append(m.ReportKeys, m.DataFiles...)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("manifest %s lists no data files", path)
+	}
+
+	dir := filepath.Dir(path)
+	files := make([]string, 0, len(keys))
+	for _, key := range keys {
+		file, err := resolveKey(dir, key)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func resolveKey(dir, key string) (string, error) {
+	if rest, ok := strings.CutPrefix(key, "s3://"); ok {
+		// drop the bucket name
+		_, key, _ = strings.Cut(rest, "/")
+	}
+
+	parts := strings.Split(key, "/")
+	for i := range parts {
+		candidate := filepath.Join(append([]string{dir}, parts[i:]...)...)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("data file %s not found under %s", key, dir)
+}
+
+// Scan calls fn for each line item in a CUR data file
+func Scan(path string, fn func(LineItem) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	name := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(name, ".parquet"):
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		return scanParquet(f, info.Size(), fn)
+	case strings.HasSuffix(name, ".gz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		return scanCSV(gz, fn)
+	}
+	return scanCSV(f, fn)
+}
+
+func scanCSV(r io.Reader, fn func(LineItem) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := make([]string, len(header))
+	for i, h := range header {
+		columns[i] = normalizeColumn(h)
+	}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		fields := make(map[string]any, len(columns))
+		for i, v := range record {
+			if i < len(columns) && v != "" {
+				fields[columns[i]] = v
+			}
+		}
+
+		item, err := newLineItem(fields)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+}
+
+func scanParquet(r io.ReaderAt, size int64, fn func(LineItem) error) error {
+	file, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return err
+	}
+
+	// timestamps are read as raw integers, so keep each column's unit
+	units := make(map[string]time.Duration)
+	for _, field := range file.Schema().Fields() {
+		if lt := field.Type().LogicalType(); lt != nil {
+			if ts, ok := lt.Value.(*format.TimestampType); ok && ts.Unit.Value != nil {
+				units[normalizeColumn(field.Name())] = ts.Unit.Value.Duration()
+			}
+		}
+	}
+
+	reader := parquet.NewGenericReader[any](file)
+	defer reader.Close()
+
+	rows := make([]any, 256)
+	for {
+		n, err := reader.Read(rows)
+		for _, row := range rows[:n] {
+			values, ok := row.(map[string]any)
+			if !ok {
+				return fmt.Errorf("unexpected row type %T", row)
+			}
+
+			fields := make(map[string]any, len(values))
+			for name, v := range values {
+				column := normalizeColumn(name)
+				if unit, ok := units[column]; ok {
+					if ns, ok := v.(int64); ok {
+						v = time.Unix(0, ns*int64(unit)).UTC()
+					}
+				}
+				fields[column] = v
+			}
+
+			item, err := newLineItem(fields)
+			if err != nil {
+				return err
+			}
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// newLineItem builds a line item from columns named as in CUR 2.0
+func newLineItem(fields map[string]any) (LineItem, error) {
+	item := LineItem{
+		Account:      stringValue(fields["line_item_usage_account_id"]),
+		Service:      firstString(fields, "product_product_name", "line_item_product_code"),
+		Region:       firstString(fields, "product_region_code", "product_region"),
+		UsageType:    stringValue(fields["line_item_usage_type"]),
+		Operation:    stringValue(fields["line_item_operation"]),
+		ResourceID:   stringValue(fields["line_item_resource_id"]),
+		LineItemType: stringValue(fields["line_item_line_item_type"]),
+		Currency:     stringValue(fields["line_item_currency_code"]),
+	}
+
+	start, err := timeValue(fields["line_item_usage_start_date"])
+	if err != nil {
+		return LineItem{}, fmt.Errorf("usage start date: %w", err)
+	}
+	item.UsageStart = start
+
+	cost, err := floatValue(fields["line_item_unblended_cost"])
+	if err != nil {
+		return LineItem{}, fmt.Errorf("unblended cost: %w", err)
+	}
+	item.Cost = cost
+
+	// CUR 2.0 keeps tags and cost categories in map columns, legacy reports
+	// in one column each
+	item.Tags = mapValue(fields["resource_tags"])
+	item.CostCategories = mapValue(fields["cost_category"])
+	for name, v := range fields {
+		if key, ok := strings.CutPrefix(name, "resource_tags_"); ok {
+			item.Tags = setValue(item.Tags, key, stringValue(v))
+		}
+		if key, ok := strings.CutPrefix(name, "cost_category_"); ok {
+			item.CostCategories = setValue(item.CostCategories, key, stringValue(v))
+		}
+	}
+	item.Tags = userTags(item.Tags)
+	return item, nil
+}
+
+// userTags strips the user prefix CUR adds to user-defined tag keys, so tag
+// groups use the same keys as Cost Explorer
+func userTags(tags map[string]string) map[string]string {
+	for key, v := range tags {
+		for _, prefix := range []string{"user:", "user_"} {
+			if name, ok := strings.CutPrefix(key, prefix); ok {
+				delete(tags, key)
+				tags[name] = v
+			}
+		}
+	}
+	return tags
+}
+
+func setValue(m map[string]string, key, value string) map[string]string {
+	if value == "" {
+		return m
+	}
+	if m == nil {
+		m = make(map[string]string)
+	}
+	m[key] = value
+	return m
+}
+
+// normalizeColumn converts a legacy column name such as lineItem/UnblendedCost
+// or resourceTags/user:env into its CUR 2.0 form (line_item_unblended_cost,
+// resource_tags_user:env)
+func normalizeColumn(name string) string {
+	name = strings.TrimSpace(name)
+	for _, prefix := range []string{"resourceTags/", "costCategory/"} {
+		if key, ok := strings.CutPrefix(name, prefix); ok {
+			return snakeCase(strings.TrimSuffix(prefix, "/")) + "_" + key
+		}
+	}
+
+	parts := strings.Split(name, "/")
+	for i, p := range parts {
+		parts[i] = snakeCase(p)
+	}
+	return strings.Join(parts, "_")
+}
+
+// snakeCase converts camelCase and PascalCase to snake_case, keeping runs of
+// capitals such as RI together
+func snakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+func firstString(fields map[string]any, names ...string) string {
+	for _, name := range names {
+		if v := stringValue(fields[name]); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func stringValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(v)
+}
+
+func floatValue(v any) (float64, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	}
+	return strconv.ParseFloat(stringValue(v), 64)
+}
+
+func timeValue(v any) (time.Time, error) {
+	switch v := v.(type) {
+	case nil:
+		return time.Time{}, errors.New("missing")
+	case time.Time:
+		return v.UTC(), nil
+	}
+	// RFC 3339 parsing also accepts the fractional seconds CUR 2.0 writes
+	t, err := time.Parse(time.RFC3339, stringValue(v))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
+
+// mapValue reads a map column, which is a JSON object in CSV files
+func mapValue(v any) map[string]string {
+	m := make(map[string]string)
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if s := stringValue(value); s != "" {
+				m[key] = s
+			}
+		}
+	case string:
+		var decoded map[string]string
+		if json.Unmarshal([]byte(v), &decoded) == nil {
+			for key, value := range decoded {
+				if value != "" {
+					m[key] = value
+				}
+			}
+		}
+	}
+	return m
+}
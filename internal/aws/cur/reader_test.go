@@ -0,0 +1,186 @@
+package cur
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestNormalizeColumn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "lineItem/UnblendedCost", expected: "line_item_unblended_cost"},
+		{input: "lineItem/LineItemType", expected: "line_item_line_item_type"},
+		{input: "lineItem/UsageAccountId", expected: "line_item_usage_account_id"},
+		{input: "product/ProductName", expected: "product_product_name"},
+		{input: "reservation/RICostForUnusedHours", expected: "reservation_ri_cost_for_unused_hours"},
+		{input: "resourceTags/user:Cost Center", expected: "resource_tags_user:Cost Center"},
+		{input: "costCategory/team", expected: "cost_category_team"},
+		{input: "line_item_unblended_cost", expected: "line_item_unblended_cost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := normalizeColumn(tt.input); got != tt.expected {
+				t.Errorf("got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestScanLegacyCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report-1.csv.gz")
+	writeGzip(t, path, strings.Join([]string{
+		"identity/LineItemId,lineItem/UsageAccountId,lineItem/LineItemType,lineItem/UsageStartDate,lineItem/ProductCode,lineItem/UsageType,lineItem/ResourceId,lineItem/UnblendedCost,lineItem/CurrencyCode,product/ProductName,product/region,resourceTags/user:env",
+		"a,111111111111,Usage,2024-03-01T05:00:00Z,AmazonEC2,BoxUsage:m5.large,i-0abc,1.25,USD,Amazon Elastic Compute Cloud,us-east-1,prod",
+		"b,111111111111,Tax,2024-03-02T00:00:00Z,AmazonEC2,,,0.10,USD,Amazon Elastic Compute Cloud,,",
+	}, "\n"))
+
+	var items []LineItem
+	if err := Scan(path, func(item LineItem) error {
+		items = append(items, item)
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("length: got %d, want 2", len(items))
+	}
+	first := items[0]
+	if first.Service != "Amazon Elastic Compute Cloud" || first.ResourceID != "i-0abc" || first.Cost != 1.25 || first.Region != "us-east-1" {
+		t.Errorf("first: got %+v", first)
+	}
+	if first.Tags["env"] != "prod" {
+		t.Errorf("tags: got %v, want env=prod", first.Tags)
+	}
+	if !first.UsageStart.Equal(time.Date(2024, 3, 1, 5, 0, 0, 0, time.UTC)) {
+		t.Errorf("usage start: got %s", first.UsageStart)
+	}
+	if items[1].LineItemType != "Tax" || len(items[1].Tags) != 0 {
+		t.Errorf("second: got %+v", items[1])
+	}
+}
+
+type parquetRow struct {
+	Account  string            `parquet:"line_item_usage_account_id"`
+	Type     string            `parquet:"line_item_line_item_type"`
+	Start    time.Time         `parquet:"line_item_usage_start_date,timestamp(millisecond)"`
+	Product  string            `parquet:"line_item_product_code"`
+	Resource string            `parquet:"line_item_resource_id,optional"`
+	Cost     float64           `parquet:"line_item_unblended_cost"`
+	Currency string            `parquet:"line_item_currency_code"`
+	Tags     map[string]string `parquet:"resource_tags"`
+}
+
+func writeParquet(t *testing.T, path string, rows []parquetRow) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := parquet.NewGenericWriter[parquetRow](f)
+	if _, err := w.Write(rows); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeGzip(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	if _, err := gz.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScanParquet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "part-0.parquet")
+	writeParquet(t, path, []parquetRow{
+		{
+			Account:  "222222222222",
+			Type:     "Usage",
+			Start:    time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC),
+			Product:  "AmazonS3",
+			Resource: "my-bucket",
+			Cost:     3.5,
+			Currency: "USD",
+			Tags:     map[string]string{"user_team": "data"},
+		},
+	})
+
+	var items []LineItem
+	if err := Scan(path, func(item LineItem) error {
+		items = append(items, item)
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(items) != 1 {
+		t.Fatalf("length: got %d, want 1", len(items))
+	}
+	item := items[0]
+	if item.Service != "AmazonS3" || item.ResourceID != "my-bucket" || item.Cost != 3.5 || item.Tags["team"] != "data" {
+		t.Errorf("item: got %+v", item)
+	}
+	if !item.UsageStart.Equal(time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("usage start: got %s", item.UsageStart)
+	}
+}
+
+func TestFilesManifest(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data", "BILLING_PERIOD=2024-03")
+	if err := os.MkdirAll(data, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeParquet(t, filepath.Join(data, "part-0.parquet"), nil)
+	writeParquet(t, filepath.Join(data, "stale.parquet"), nil)
+
+	manifest := filepath.Join(dir, "Manifest.json")
+	content := `{"dataFiles": ["s3://billing-bucket/exports/report/data/BILLING_PERIOD=2024-03/part-0.parquet"]}`
+	if err := os.WriteFile(manifest, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Files(manifest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 1 || files[0] != filepath.Join(data, "part-0.parquet") {
+		t.Errorf("manifest files: got %v", files)
+	}
+
+	files, err = Files(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("directory files: got %v, want 2 parquet files", files)
+	}
+
+	if _, err := Files(t.TempDir()); err == nil {
+		t.Error("empty directory: expected error, got nil")
+	}
+}
@@ -0,0 +1,157 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+// maxSortedResults is the most values Cost Explorer returns for a sorted
+// request, which cannot be paged
+const maxSortedResults = 1000
+
+// DimensionValue is a value of a dimension, with attributes such as the
+// description of a linked account
+type DimensionValue struct {
+	Value      string            `json:"value"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// DiscoveryQuery selects dimension or tag values seen within Period. Search
+// matches values containing it, Limit caps the number of values (0 = all)
+// and SortByCost orders values by unblended cost instead of by name. Cost
+// Explorer does not page or search sorted results.
+type DiscoveryQuery struct {
+	Period     period.Range
+	Search     string
+	Limit      int
+	SortByCost bool
+	Filter     *types.Expression
+}
+
+// Validate reports options Cost Explorer cannot combine
+func (q DiscoveryQuery) Validate() error {
+	if q.SortByCost && q.Search != "" {
+		return fmt.Errorf("search cannot be combined with sorting by cost")
+	}
+	return nil
+}
+
+// sortBy returns the SortBy and MaxResults of a request for q
+func (q DiscoveryQuery) sortBy() ([]types.SortDefinition, *int32) {
+	if !q.SortByCost {
+		return nil, nil
+	}
+	limit := maxSortedResults
+	if q.Limit > 0 && q.Limit < limit {
+		limit = q.Limit
+	}
+	return []types.SortDefinition{{Key: aws.String(DefaultMetric), SortOrder: types.SortOrderDescending}}, aws.Int32(int32(limit))
+}
+
+// ParseDimension converts a dimension name such as "service", "usage-type" or
+// "account" into its Cost Explorer value
+func ParseDimension(s string) (types.Dimension, error) {
+	key := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s), "-", "_"))
+	if alias, ok := groupByAliases[key]; ok {
+		key = alias
+	}
+	for _, d := range types.Dimension("").Values() {
+		if string(d) == key {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("unknown dimension %q", s)
+}
+
+// GetDimensionValues returns the values of dimension with costs in q.Period
+func (c *Client) GetDimensionValues(ctx context.Context, dimension types.Dimension, q DiscoveryQuery) ([]DimensionValue, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	sortBy, maxResults := q.sortBy()
+
+	var values []DimensionValue
+	var token *string
+
+	for {
+		input := &costexplorer.GetDimensionValuesInput{
+			Dimension:     dimension,
+			TimePeriod:    dateInterval(q.Period, false),
+			Context:       types.ContextCostAndUsage,
+			Filter:        q.Filter,
+			SortBy:        sortBy,
+			MaxResults:    maxResults,
+			NextPageToken: token,
+		}
+		if q.Search != "" {
+			input.SearchString = aws.String(q.Search)
+		}
+
+		output, err := c.ce.GetDimensionValues(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range output.DimensionValues {
+			values = append(values, DimensionValue{Value: aws.ToString(v.Value), Attributes: v.Attributes})
+		}
+
+		if q.Limit > 0 && len(values) >= q.Limit {
+			return values[:q.Limit], nil
+		}
+		if aws.ToString(output.NextPageToken) == "" {
+			break
+		}
+		token = output.NextPageToken
+	}
+	return values, nil
+}
+
+// GetTags returns the tag keys with costs in q.Period, or the values of key
+// when it is set. Resources without the tag show up as an empty value.
+func (c *Client) GetTags(ctx context.Context, key string, q DiscoveryQuery) ([]string, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	sortBy, maxResults := q.sortBy()
+
+	var tags []string
+	var token *string
+
+	for {
+		input := &costexplorer.GetTagsInput{
+			TimePeriod:    dateInterval(q.Period, false),
+			Filter:        q.Filter,
+			SortBy:        sortBy,
+			MaxResults:    maxResults,
+			NextPageToken: token,
+		}
+		if key != "" {
+			input.TagKey = aws.String(key)
+		}
+		if q.Search != "" {
+			input.SearchString = aws.String(q.Search)
+		}
+
+		output, err := c.ce.GetTags(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, output.Tags...)
+
+		if q.Limit > 0 && len(tags) >= q.Limit {
+			return tags[:q.Limit], nil
+		}
+		if aws.ToString(output.NextPageToken) == "" {
+			break
+		}
+		token = output.NextPageToken
+	}
+	return tags, nil
+}
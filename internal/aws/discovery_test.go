@@ -0,0 +1,139 @@
+package aws
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+func TestParseDimension(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    types.Dimension
+		wantErr bool
+	}{
+		{input: "service", want: types.DimensionService},
+		{input: "usage-type", want: types.DimensionUsageType},
+		{input: "account", want: types.DimensionLinkedAccount},
+		{input: "RECORD_TYPE", want: types.DimensionRecordType},
+		{input: "colour", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDimension(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error: got %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetDimensionValues(t *testing.T) {
+	mock := &mockCostExplorer{
+		dimensionValues: []*costexplorer.GetDimensionValuesOutput{
+			{
+				DimensionValues: []types.DimensionValuesWithAttributes{
+					{Value: aws.String("111111111111"), Attributes: map[string]string{"description": "payments-prod"}},
+				},
+				NextPageToken: aws.String("page-2"),
+			},
+			{
+				DimensionValues: []types.DimensionValuesWithAttributes{
+					{Value: aws.String("222222222222")},
+				},
+			},
+		},
+	}
+
+	client := NewClientWithAPI(mock)
+	values, err := client.GetDimensionValues(context.Background(), types.DimensionLinkedAccount, DiscoveryQuery{
+		Period: period.LastDays(30, time.Now()),
+		Search: "2222",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []DimensionValue{
+		{Value: "111111111111", Attributes: map[string]string{"description": "payments-prod"}},
+		{Value: "222222222222"},
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("values: got %+v, want %+v", values, want)
+	}
+	if len(mock.dimensionCalls) != 2 || aws.ToString(mock.dimensionCalls[1].NextPageToken) != "page-2" {
+		t.Errorf("paging: got %d calls", len(mock.dimensionCalls))
+	}
+	first := mock.dimensionCalls[0]
+	if first.Dimension != types.DimensionLinkedAccount || aws.ToString(first.SearchString) != "2222" || first.Context != types.ContextCostAndUsage {
+		t.Errorf("input: got %+v", first)
+	}
+}
+
+func TestGetDimensionValuesLimit(t *testing.T) {
+	mock := &mockCostExplorer{
+		dimensionValues: []*costexplorer.GetDimensionValuesOutput{
+			{
+				DimensionValues: []types.DimensionValuesWithAttributes{
+					{Value: aws.String("Amazon EC2")},
+					{Value: aws.String("Amazon S3")},
+				},
+				NextPageToken: aws.String("page-2"),
+			},
+		},
+	}
+
+	client := NewClientWithAPI(mock)
+	values, err := client.GetDimensionValues(context.Background(), types.DimensionService, DiscoveryQuery{Limit: 1, SortByCost: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(values) != 1 || values[0].Value != "Amazon EC2" {
+		t.Errorf("values: got %+v", values)
+	}
+	if len(mock.dimensionCalls) != 1 {
+		t.Errorf("calls: got %d, want 1", len(mock.dimensionCalls))
+	}
+	input := mock.dimensionCalls[0]
+	if len(input.SortBy) != 1 || aws.ToString(input.SortBy[0].Key) != DefaultMetric || aws.ToInt32(input.MaxResults) != 1 {
+		t.Errorf("sort: got %+v, max %d", input.SortBy, aws.ToInt32(input.MaxResults))
+	}
+}
+
+func TestGetTags(t *testing.T) {
+	mock := &mockCostExplorer{
+		tags: []*costexplorer.GetTagsOutput{
+			{Tags: []string{"", "payments"}, NextPageToken: aws.String("page-2")},
+			{Tags: []string{"search"}},
+		},
+	}
+
+	client := NewClientWithAPI(mock)
+	tags, err := client.GetTags(context.Background(), "team", DiscoveryQuery{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(tags, []string{"", "payments", "search"}) {
+		t.Errorf("tags: got %v", tags)
+	}
+	if aws.ToString(mock.tagCalls[0].TagKey) != "team" {
+		t.Errorf("tag key: got %q", aws.ToString(mock.tagCalls[0].TagKey))
+	}
+}
+
+func TestDiscoveryQueryValidate(t *testing.T) {
+	client := NewClientWithAPI(&mockCostExplorer{})
+	if _, err := client.GetTags(context.Background(), "", DiscoveryQuery{Search: "team", SortByCost: true}); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
@@ -0,0 +1,99 @@
+package aws
+
+import (
+	"fmt"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/filter"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+// Filter matches costs whose dimension, tag or cost category has one of
+// Values. A tag or cost category filter without values matches costs where
+// the key is absent.
+type Filter struct {
+	GroupBy
+	Values []string
+}
+
+// ParseFilter parses a filter of the form "<key>=<value>[,<value>...]", where
+// key is anything accepted by ParseGroupBy, e.g. "service=Amazon EC2",
+// "record-type=Credit,Refund" or "tag:env=prod"
+func ParseFilter(s string) (Filter, error) {
+	spec, err := filter.Parse(s)
+	if err != nil {
+		return Filter{}, err
+	}
+
+	g, err := ParseGroupBy(spec.Key)
+	if err != nil {
+		return Filter{}, fmt.Errorf("invalid filter %q: %w", s, err)
+	}
+
+	f := Filter{GroupBy: g, Values: spec.Values}
+	if len(f.Values) == 0 && g.Type == types.GroupDefinitionTypeDimension {
+		return Filter{}, fmt.Errorf("invalid filter %q: no values", s)
+	}
+	return f, nil
+}
+
+// ParseFilters parses each value with ParseFilter
+func ParseFilters(values []string) ([]Filter, error) {
+	filters := make([]Filter, len(values))
+	for i, v := range values {
+		f, err := ParseFilter(v)
+		if err != nil {
+			return nil, err
+		}
+		filters[i] = f
+	}
+	return filters, nil
+}
+
+// Expression converts f into a Cost Explorer expression
+func (f Filter) Expression() types.Expression {
+	var match []types.MatchOption
+	if len(f.Values) == 0 {
+		match = []types.MatchOption{types.MatchOptionAbsent}
+	}
+
+	switch f.Type {
+	case types.GroupDefinitionTypeTag:
+		return types.Expression{Tags: &types.TagValues{
+			Key:          aws.String(f.Key),
+			Values:       f.Values,
+			MatchOptions: match,
+		}}
+	case types.GroupDefinitionTypeCostCategory:
+		return types.Expression{CostCategories: &types.CostCategoryValues{
+			Key:          aws.String(f.Key),
+			Values:       f.Values,
+			MatchOptions: match,
+		}}
+	}
+	return types.Expression{Dimensions: &types.DimensionValues{
+		Key:    types.Dimension(f.Key),
+		Values: f.Values,
+	}}
+}
+
+// BuildFilter combines include filters and the negation of exclude filters
+// with And. It returns nil when there is nothing to filter on.
+func BuildFilter(include, exclude []Filter) *types.Expression {
+	var exprs []types.Expression
+	for _, f := range include {
+		exprs = append(exprs, f.Expression())
+	}
+	for _, f := range exclude {
+		not := f.Expression()
+		exprs = append(exprs, types.Expression{Not: &not})
+	}
+
+	switch len(exprs) {
+	case 0:
+		return nil
+	case 1:
+		return &exprs[0]
+	}
+	return &types.Expression{And: exprs}
+}
@@ -0,0 +1,179 @@
+package aws
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Filter
+		wantErr  bool
+	}{
+		{
+			name:  "service with spaces",
+			input: "service=Amazon EC2",
+			expected: Filter{
+				GroupBy: GroupBy{Type: types.GroupDefinitionTypeDimension, Key: "SERVICE"},
+				Values:  []string{"Amazon EC2"},
+			},
+		},
+		{
+			name:  "multiple values",
+			input: "record-type=Credit,Refund, Tax",
+			expected: Filter{
+				GroupBy: GroupBy{Type: types.GroupDefinitionTypeDimension, Key: "RECORD_TYPE"},
+				Values:  []string{"Credit", "Refund", "Tax"},
+			},
+		},
+		{
+			name:  "tag",
+			input: "tag:env=prod",
+			expected: Filter{
+				GroupBy: GroupBy{Type: types.GroupDefinitionTypeTag, Key: "env"},
+				Values:  []string{"prod"},
+			},
+		},
+		{
+			name:  "untagged",
+			input: "tag:team=",
+			expected: Filter{
+				GroupBy: GroupBy{Type: types.GroupDefinitionTypeTag, Key: "team"},
+			},
+		},
+		{
+			name:    "missing equals",
+			input:   "service",
+			wantErr: true,
+		},
+		{
+			name:    "dimension without values",
+			input:   "region=",
+			wantErr: true,
+		},
+		{
+			name:    "unknown key",
+			input:   "colour=red",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseFilter(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("got %+v, want %+v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuildFilter(t *testing.T) {
+	service := Filter{GroupBy: ServiceGroupBy, Values: []string{"Amazon EC2"}}
+	credits := Filter{
+		GroupBy: GroupBy{Type: types.GroupDefinitionTypeDimension, Key: "RECORD_TYPE"},
+		Values:  []string{"Credit", "Refund"},
+	}
+	untagged := Filter{GroupBy: GroupBy{Type: types.GroupDefinitionTypeTag, Key: "team"}}
+
+	tests := []struct {
+		name     string
+		include  []Filter
+		exclude  []Filter
+		expected *types.Expression
+	}{
+		{
+			name:     "no filters",
+			expected: nil,
+		},
+		{
+			name:    "single include",
+			include: []Filter{service},
+			expected: &types.Expression{
+				Dimensions: &types.DimensionValues{Key: types.DimensionService, Values: []string{"Amazon EC2"}},
+			},
+		},
+		{
+			name:    "single exclude",
+			exclude: []Filter{credits},
+			expected: &types.Expression{
+				Not: &types.Expression{
+					Dimensions: &types.DimensionValues{Key: types.DimensionRecordType, Values: []string{"Credit", "Refund"}},
+				},
+			},
+		},
+		{
+			name:    "include and exclude",
+			include: []Filter{service, untagged},
+			exclude: []Filter{credits},
+			expected: &types.Expression{
+				And: []types.Expression{
+					{Dimensions: &types.DimensionValues{Key: types.DimensionService, Values: []string{"Amazon EC2"}}},
+					{Tags: &types.TagValues{Key: aws.String("team"), MatchOptions: []types.MatchOption{types.MatchOptionAbsent}}},
+					{Not: &types.Expression{
+						Dimensions: &types.DimensionValues{Key: types.DimensionRecordType, Values: []string{"Credit", "Refund"}},
+					}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := BuildFilter(tt.include, tt.exclude)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("got %+v, want %+v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetCostsFilterInput(t *testing.T) {
+	include, err := ParseFilters([]string{"tag:env=prod"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	exclude, err := ParseFilters([]string{"record-type=Credit,Refund,Tax"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mock := &mockCostExplorer{output: &costexplorer.GetCostAndUsageOutput{}}
+	client := NewClientWithAPI(mock)
+	if _, _, err := client.GetCosts(context.Background(), CostQuery{Period: period.LastDays(30, time.Now()), Filter: BuildFilter(include, exclude)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	filter := mock.calls[0].Filter
+	if filter == nil || len(filter.And) != 2 {
+		t.Fatalf("filter: got %+v, want And of 2 expressions", filter)
+	}
+	if tags := filter.And[0].Tags; tags == nil || aws.ToString(tags.Key) != "env" || !reflect.DeepEqual(tags.Values, []string{"prod"}) {
+		t.Errorf("include: got %+v", filter.And[0])
+	}
+	not := filter.And[1].Not
+	if not == nil || not.Dimensions == nil || not.Dimensions.Key != types.DimensionRecordType {
+		t.Fatalf("exclude: got %+v", filter.And[1])
+	}
+	if !reflect.DeepEqual(not.Dimensions.Values, []string{"Credit", "Refund", "Tax"}) {
+		t.Errorf("exclude values: got %v", not.Dimensions.Values)
+	}
+}
@@ -0,0 +1,132 @@
+package aws
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+// DefaultPredictionInterval is the prediction interval level, in percent,
+// used when a forecast query does not set one
+const DefaultPredictionInterval = 80
+
+// ForecastQuery describes a cost forecast. Period must start today or later.
+type ForecastQuery struct {
+	Period             period.Range
+	Granularity        types.Granularity
+	Metric             string
+	Filter             *types.Expression
+	PredictionInterval int32
+}
+
+// Forecast is the forecast cost for a window. Lower and Upper bound the total
+// only when the window is a single period: summing per-period bounds does not
+// give the interval of the sum, and Cost Explorer returns no total interval.
+type Forecast struct {
+	Start   string           `json:"start"`
+	End     string           `json:"end"`
+	Metric  string           `json:"metric"`
+	Mean    float64          `json:"mean"`
+	Lower   *float64         `json:"lower,omitempty"`
+	Upper   *float64         `json:"upper,omitempty"`
+	Unit    string           `json:"unit"`
+	Periods []ForecastPeriod `json:"periods"`
+}
+
+// ForecastPeriod is the forecast cost for one time bucket
+type ForecastPeriod struct {
+	Start string  `json:"start"`
+	End   string  `json:"end"`
+	Mean  float64 `json:"mean"`
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// GetCostForecast returns the forecast for q
+func (c *Client) GetCostForecast(ctx context.Context, q ForecastQuery) (*Forecast, error) {
+	output, err := c.ce.GetCostForecast(ctx, q.Input())
+	if err != nil {
+		return nil, err
+	}
+
+	forecast := ParseForecastResponse(output)
+	forecast.Start = q.Period.Start.Format(period.DateLayout)
+	forecast.End = q.Period.End.Format(period.DateLayout)
+	forecast.Metric = q.metric()
+	return forecast, nil
+}
+
+// Input builds the GetCostForecast request for q
+func (q ForecastQuery) Input() *costexplorer.GetCostForecastInput {
+	granularity := q.Granularity
+	if granularity == "" {
+		granularity = types.GranularityMonthly
+	}
+	level := q.PredictionInterval
+	if level == 0 {
+		level = DefaultPredictionInterval
+	}
+
+	return &costexplorer.GetCostForecastInput{
+		TimePeriod:              dateInterval(q.Period, false),
+		Granularity:             granularity,
+		Metric:                  ForecastMetric(q.metric()),
+		Filter:                  q.Filter,
+		PredictionIntervalLevel: aws.Int32(level),
+	}
+}
+
+func (q ForecastQuery) metric() string {
+	if q.Metric == "" {
+		return DefaultMetric
+	}
+	return q.Metric
+}
+
+// ForecastMetric converts a GetCostAndUsage metric name such as
+// "NetAmortizedCost" into the form GetCostForecast expects, e.g.
+// "NET_AMORTIZED_COST"
+func ForecastMetric(metric string) types.Metric {
+	var b strings.Builder
+	for i, r := range metric {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return types.Metric(b.String())
+}
+
+// ParseForecastResponse parses a GetCostForecast response into a Forecast
+func ParseForecastResponse(output *costexplorer.GetCostForecastOutput) *Forecast {
+	forecast := &Forecast{}
+	if output.Total != nil {
+		forecast.Mean, _ = strconv.ParseFloat(aws.ToString(output.Total.Amount), 64)
+		forecast.Unit = aws.ToString(output.Total.Unit)
+	}
+
+	for _, r := range output.ForecastResultsByTime {
+		p := ForecastPeriod{}
+		if r.TimePeriod != nil {
+			p.Start = aws.ToString(r.TimePeriod.Start)
+			p.End = aws.ToString(r.TimePeriod.End)
+		}
+		p.Mean, _ = strconv.ParseFloat(aws.ToString(r.MeanValue), 64)
+		p.Lower, _ = strconv.ParseFloat(aws.ToString(r.PredictionIntervalLowerBound), 64)
+		p.Upper, _ = strconv.ParseFloat(aws.ToString(r.PredictionIntervalUpperBound), 64)
+
+		forecast.Periods = append(forecast.Periods, p)
+	}
+
+	if len(forecast.Periods) == 1 {
+		lower, upper := forecast.Periods[0].Lower, forecast.Periods[0].Upper
+		forecast.Lower, forecast.Upper = &lower, &upper
+	}
+	return forecast
+}
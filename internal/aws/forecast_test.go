@@ -0,0 +1,133 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+func TestForecastMetric(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected types.Metric
+	}{
+		{input: "UnblendedCost", expected: types.MetricUnblendedCost},
+		{input: "NetAmortizedCost", expected: types.MetricNetAmortizedCost},
+		{input: "UsageQuantity", expected: types.MetricUsageQuantity},
+		{input: "NormalizedUsageAmount", expected: types.MetricNormalizedUsageAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := ForecastMetric(tt.input); got != tt.expected {
+				t.Errorf("got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetCostForecast(t *testing.T) {
+	now := time.Date(2024, 2, 20, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		mock      *mockCostExplorer
+		query     ForecastQuery
+		wantErr   bool
+		wantMean  float64
+		wantLower *float64
+		wantUpper *float64
+		wantLevel int32
+	}{
+		{
+			name: "rest of month",
+			mock: &mockCostExplorer{
+				forecast: &costexplorer.GetCostForecastOutput{
+					Total: &types.MetricValue{Amount: aws.String("300.00"), Unit: aws.String("USD")},
+					ForecastResultsByTime: []types.ForecastResult{
+						{
+							TimePeriod:                   &types.DateInterval{Start: aws.String("2024-02-20"), End: aws.String("2024-03-01")},
+							MeanValue:                    aws.String("300.00"),
+							PredictionIntervalLowerBound: aws.String("250.00"),
+							PredictionIntervalUpperBound: aws.String("360.00"),
+						},
+					},
+				},
+			},
+			query:     ForecastQuery{Period: period.RestOfMonth(now)},
+			wantMean:  300.00,
+			wantLower: aws.Float64(250.00),
+			wantUpper: aws.Float64(360.00),
+			wantLevel: DefaultPredictionInterval,
+		},
+		{
+			name: "daily periods have no total interval",
+			mock: &mockCostExplorer{
+				forecast: &costexplorer.GetCostForecastOutput{
+					Total: &types.MetricValue{Amount: aws.String("30.00"), Unit: aws.String("USD")},
+					ForecastResultsByTime: []types.ForecastResult{
+						{MeanValue: aws.String("10.00"), PredictionIntervalLowerBound: aws.String("8.00"), PredictionIntervalUpperBound: aws.String("12.00")},
+						{MeanValue: aws.String("20.00"), PredictionIntervalLowerBound: aws.String("15.00"), PredictionIntervalUpperBound: aws.String("25.00")},
+					},
+				},
+			},
+			query:     ForecastQuery{Period: period.NextDays(2, now), Granularity: types.GranularityDaily, PredictionInterval: 95},
+			wantMean:  30.00,
+			wantLevel: 95,
+		},
+		{
+			name:    "api error",
+			mock:    &mockCostExplorer{err: errors.New("api error")},
+			query:   ForecastQuery{Period: period.RestOfMonth(now)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClientWithAPI(tt.mock)
+			forecast, err := client.GetCostForecast(context.Background(), tt.query)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			input := tt.mock.forecastCalls[0]
+			if input.Metric != types.MetricUnblendedCost {
+				t.Errorf("metric: got %s", input.Metric)
+			}
+			if aws.ToInt32(input.PredictionIntervalLevel) != tt.wantLevel {
+				t.Errorf("interval: got %d, want %d", aws.ToInt32(input.PredictionIntervalLevel), tt.wantLevel)
+			}
+			if got := aws.ToString(input.TimePeriod.Start); got != "2024-02-20" {
+				t.Errorf("start: got %s, want 2024-02-20", got)
+			}
+
+			if math.Abs(forecast.Mean-tt.wantMean) > 0.001 {
+				t.Errorf("mean: got %f, want %f", forecast.Mean, tt.wantMean)
+			}
+			if !reflect.DeepEqual(forecast.Lower, tt.wantLower) {
+				t.Errorf("lower: got %v, want %v", aws.ToFloat64(forecast.Lower), aws.ToFloat64(tt.wantLower))
+			}
+			if !reflect.DeepEqual(forecast.Upper, tt.wantUpper) {
+				t.Errorf("upper: got %v, want %v", aws.ToFloat64(forecast.Upper), aws.ToFloat64(tt.wantUpper))
+			}
+			if forecast.Unit != "USD" {
+				t.Errorf("unit: got %s, want USD", forecast.Unit)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"gopkg.in/yaml.v3"
+)
+
+// AccountTagPrefix marks a group-by that rolls linked accounts up by one of
+// their account tags, e.g. account-tag:team
+const AccountTagPrefix = "account-tag:"
+
+// OrganizationsAPI is the part of the Organizations API used to resolve
+// account metadata
+type OrganizationsAPI interface {
+	ListAccounts(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error)
+	ListParents(ctx context.Context, params *organizations.ListParentsInput, optFns ...func(*organizations.Options)) (*organizations.ListParentsOutput, error)
+	DescribeOrganizationalUnit(ctx context.Context, params *organizations.DescribeOrganizationalUnitInput, optFns ...func(*organizations.Options)) (*organizations.DescribeOrganizationalUnitOutput, error)
+	ListTagsForResource(ctx context.Context, params *organizations.ListTagsForResourceInput, optFns ...func(*organizations.Options)) (*organizations.ListTagsForResourceOutput, error)
+}
+
+// NewOrganizationsClient creates an Organizations client for t, which must be
+// the management account or a delegated administrator
+func NewOrganizationsClient(ctx context.Context, t Target) (OrganizationsAPI, error) {
+	cfg, err := loadConfig(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	return organizations.NewFromConfig(cfg), nil
+}
+
+// AccountInfo is the metadata of an account. OUPath lists the organizational
+// units from the root down, e.g. /Workloads/Prod.
+type AccountInfo struct {
+	Name   string            `json:"name,omitempty" yaml:"name"`
+	OUPath string            `json:"ou_path,omitempty" yaml:"ou"`
+	Tags   map[string]string `json:"tags,omitempty" yaml:"tags"`
+}
+
+// Accounts maps account IDs to their metadata
+type Accounts map[string]AccountInfo
+
+// ListOrganizationAccounts returns the metadata of every account in the
+// organization
+func ListOrganizationAccounts(ctx context.Context, api OrganizationsAPI) (Accounts, error) {
+	accounts := make(Accounts)
+	paths := make(map[string]string)
+	var token *string
+
+	for {
+		output, err := api.ListAccounts(ctx, &organizations.ListAccountsInput{NextToken: token})
+		if err != nil {
+			return nil, fmt.Errorf("list accounts: %w", err)
+		}
+
+		for _, a := range output.Accounts {
+			id := aws.ToString(a.Id)
+			path, err := ouPath(ctx, api, id, paths)
+			if err != nil {
+				return nil, fmt.Errorf("account %s: %w", id, err)
+			}
+			tags, err := resourceTags(ctx, api, id)
+			if err != nil {
+				return nil, fmt.Errorf("account %s: %w", id, err)
+			}
+			accounts[id] = AccountInfo{Name: aws.ToString(a.Name), OUPath: path, Tags: tags}
+		}
+
+		if aws.ToString(output.NextToken) == "" {
+			break
+		}
+		token = output.NextToken
+	}
+	return accounts, nil
+}
+
+// ouPath walks up from id to the root, naming each organizational unit on the
+// way. Paths of units already seen are kept in cache.
+func ouPath(ctx context.Context, api OrganizationsAPI, id string, cache map[string]string) (string, error) {
+	output, err := api.ListParents(ctx, &organizations.ListParentsInput{ChildId: aws.String(id)})
+	if err != nil {
+		return "", fmt.Errorf("list parents: %w", err)
+	}
+	if len(output.Parents) == 0 || output.Parents[0].Type == orgtypes.ParentTypeRoot {
+		return "/", nil
+	}
+
+	parent := aws.ToString(output.Parents[0].Id)
+	if path, ok := cache[parent]; ok {
+		return path, nil
+	}
+
+	ou, err := api.DescribeOrganizationalUnit(ctx, &organizations.DescribeOrganizationalUnitInput{OrganizationalUnitId: aws.String(parent)})
+	if err != nil {
+		return "", fmt.Errorf("describe organizational unit %s: %w", parent, err)
+	}
+	above, err := ouPath(ctx, api, parent, cache)
+	if err != nil {
+		return "", err
+	}
+
+	path := strings.TrimSuffix(above, "/") + "/" + aws.ToString(ou.OrganizationalUnit.Name)
+	cache[parent] = path
+	return path, nil
+}
+
+func resourceTags(ctx context.Context, api OrganizationsAPI, id string) (map[string]string, error) {
+	tags := make(map[string]string)
+	var token *string
+
+	for {
+		output, err := api.ListTagsForResource(ctx, &organizations.ListTagsForResourceInput{ResourceId: aws.String(id), NextToken: token})
+		if err != nil {
+			return nil, fmt.Errorf("list tags: %w", err)
+		}
+		for _, t := range output.Tags {
+			tags[aws.ToString(t.Key)] = aws.ToString(t.Value)
+		}
+		if aws.ToString(output.NextToken) == "" {
+			break
+		}
+		token = output.NextToken
+	}
+	return tags, nil
+}
+
+// LoadAccountsFile reads account metadata from a YAML or JSON file mapping
+// account IDs to a name, ou and tags
+func LoadAccountsFile(path string) (Accounts, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var accounts Accounts
+	if err := yaml.Unmarshal(data, &accounts); err != nil {
+		return nil, fmt.Errorf("failed to parse accounts file %s: %w", path, err)
+	}
+	return accounts, nil
+}
+
+// Merge fills accounts and fields missing from a with those in fallback
+func (a Accounts) Merge(fallback Accounts) Accounts {
+	merged := make(Accounts, len(a)+len(fallback))
+	for id, info := range fallback {
+		merged[id] = info
+	}
+	for id, info := range a {
+		f := merged[id]
+		if info.Name == "" {
+			info.Name = f.Name
+		}
+		if info.OUPath == "" {
+			info.OUPath = f.OUPath
+		}
+		for k, v := range f.Tags {
+			if _, ok := info.Tags[k]; !ok {
+				if info.Tags == nil {
+					info.Tags = make(map[string]string)
+				}
+				info.Tags[k] = v
+			}
+		}
+		merged[id] = info
+	}
+	return merged
+}
+
+// Annotate sets AccountInfo on each result from the account key at index, or
+// from the result's Account when index is negative
+func (a Accounts) Annotate(results []CostResult, index int) {
+	for i := range results {
+		id := results[i].Account
+		if index >= 0 && index < len(results[i].Keys) {
+			id = results[i].Keys[index]
+		}
+		if info, ok := a[id]; ok {
+			results[i].AccountInfo = &info
+		}
+	}
+}
+
+// RollUp replaces the account key at index with the account's value for the
+// tag key, or Untagged, and merges results that end up with the same keys.
+// Results are returned largest first.
+func (a Accounts) RollUp(results []CostResult, index int, key string) []CostResult {
+	var merged []CostResult
+	positions := make(map[string]int)
+
+	for _, r := range results {
+		keys := append([]string(nil), r.Keys...)
+		value := a[keys[index]].Tags[key]
+		if value == "" {
+			value = Untagged
+		}
+		keys[index] = value
+
+		id := r.Account + "\x00" + strings.Join(keys, "\x00")
+		pos, ok := positions[id]
+		if !ok {
+			r.Keys = keys
+			r.Metrics = copyMetrics(r.Metrics)
+			r.Periods = append([]PeriodCost(nil), r.Periods...)
+			positions[id] = len(merged)
+			merged = append(merged, r)
+			continue
+		}
+
+		m := &merged[pos]
+		m.Amount += r.Amount
+		for name, v := range r.Metrics {
+			m.Metrics[name] += v
+		}
+		for p := range r.Periods {
+			if p < len(m.Periods) {
+				m.Periods[p].Amount += r.Periods[p].Amount
+			}
+		}
+	}
+	return SortByAmount(merged)
+}
+
+func copyMetrics(metrics map[string]float64) map[string]float64 {
+	if metrics == nil {
+		return nil
+	}
+	out := make(map[string]float64, len(metrics))
+	for k, v := range metrics {
+		out[k] = v
+	}
+	return out
+}
+
+// TagString formats tags as sorted key=value pairs
+func (info AccountInfo) TagString() string {
+	pairs := make([]string, 0, len(info.Tags))
+	for k, v := range info.Tags {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
@@ -0,0 +1,182 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+)
+
+// mockOrganizations serves a fixed organization: parents maps a child ID to
+// its parent, and ous maps OU IDs to names
+type mockOrganizations struct {
+	accounts  []orgtypes.Account
+	parents   map[string]orgtypes.Parent
+	ous       map[string]string
+	tags      map[string][]orgtypes.Tag
+	err       error
+	describes int
+}
+
+func (m *mockOrganizations) ListAccounts(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+	return &organizations.ListAccountsOutput{Accounts: m.accounts}, m.err
+}
+
+func (m *mockOrganizations) ListParents(ctx context.Context, params *organizations.ListParentsInput, optFns ...func(*organizations.Options)) (*organizations.ListParentsOutput, error) {
+	return &organizations.ListParentsOutput{Parents: []orgtypes.Parent{m.parents[aws.ToString(params.ChildId)]}}, nil
+}
+
+func (m *mockOrganizations) DescribeOrganizationalUnit(ctx context.Context, params *organizations.DescribeOrganizationalUnitInput, optFns ...func(*organizations.Options)) (*organizations.DescribeOrganizationalUnitOutput, error) {
+	m.describes++
+	id := params.OrganizationalUnitId
+	return &organizations.DescribeOrganizationalUnitOutput{
+		OrganizationalUnit: &orgtypes.OrganizationalUnit{Id: id, Name: aws.String(m.ous[aws.ToString(id)])},
+	}, nil
+}
+
+func (m *mockOrganizations) ListTagsForResource(ctx context.Context, params *organizations.ListTagsForResourceInput, optFns ...func(*organizations.Options)) (*organizations.ListTagsForResourceOutput, error) {
+	return &organizations.ListTagsForResourceOutput{Tags: m.tags[aws.ToString(params.ResourceId)]}, nil
+}
+
+func TestListOrganizationAccounts(t *testing.T) {
+	root := orgtypes.Parent{Id: aws.String("r-root"), Type: orgtypes.ParentTypeRoot}
+	mock := &mockOrganizations{
+		accounts: []orgtypes.Account{
+			{Id: aws.String("111111111111"), Name: aws.String("payments-prod")},
+			{Id: aws.String("222222222222"), Name: aws.String("payments-dev")},
+			{Id: aws.String("333333333333"), Name: aws.String("management")},
+		},
+		parents: map[string]orgtypes.Parent{
+			"111111111111": {Id: aws.String("ou-prod"), Type: orgtypes.ParentTypeOrganizationalUnit},
+			"222222222222": {Id: aws.String("ou-prod"), Type: orgtypes.ParentTypeOrganizationalUnit},
+			"333333333333": root,
+			"ou-prod":      {Id: aws.String("ou-workloads"), Type: orgtypes.ParentTypeOrganizationalUnit},
+			"ou-workloads": root,
+		},
+		ous: map[string]string{"ou-prod": "Prod", "ou-workloads": "Workloads"},
+		tags: map[string][]orgtypes.Tag{
+			"111111111111": {{Key: aws.String("team"), Value: aws.String("payments")}},
+		},
+	}
+
+	accounts, err := ListOrganizationAccounts(context.Background(), mock)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	prod := accounts["111111111111"]
+	if prod.Name != "payments-prod" || prod.OUPath != "/Workloads/Prod" || prod.Tags["team"] != "payments" {
+		t.Errorf("prod: got %+v", prod)
+	}
+	if got := accounts["333333333333"].OUPath; got != "/" {
+		t.Errorf("management ou path: got %q, want /", got)
+	}
+	if mock.describes != 2 {
+		t.Errorf("describe calls: got %d, want 2 (cached)", mock.describes)
+	}
+}
+
+func TestListOrganizationAccountsError(t *testing.T) {
+	if _, err := ListOrganizationAccounts(context.Background(), &mockOrganizations{err: errors.New("not in organization")}); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestLoadAccountsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.yaml")
+	content := `"111111111111":
+  name: payments-prod
+  ou: /Workloads/Prod
+  tags:
+    team: payments
+staging:
+  name: staging profile
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts, err := LoadAccountsFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := accounts["111111111111"]; got.Name != "payments-prod" || got.OUPath != "/Workloads/Prod" || got.Tags["team"] != "payments" {
+		t.Errorf("account: got %+v", got)
+	}
+	if accounts["staging"].Name != "staging profile" {
+		t.Errorf("profile alias: got %+v", accounts["staging"])
+	}
+}
+
+func TestAccountsMerge(t *testing.T) {
+	org := Accounts{"111": {Name: "prod", Tags: map[string]string{"team": "payments"}}}
+	file := Accounts{
+		"111": {Name: "ignored", OUPath: "/Prod", Tags: map[string]string{"team": "ignored", "owner": "alice"}},
+		"222": {Name: "outside-org"},
+	}
+
+	merged := org.Merge(file)
+	got := merged["111"]
+	if got.Name != "prod" || got.OUPath != "/Prod" || got.Tags["team"] != "payments" || got.Tags["owner"] != "alice" {
+		t.Errorf("merged: got %+v", got)
+	}
+	if merged["222"].Name != "outside-org" {
+		t.Errorf("fallback only: got %+v", merged["222"])
+	}
+}
+
+func TestAccountsRollUp(t *testing.T) {
+	accounts := Accounts{
+		"111": {Tags: map[string]string{"team": "payments"}},
+		"222": {Tags: map[string]string{"team": "payments"}},
+		"333": {Tags: map[string]string{"team": "search"}},
+	}
+	results := []CostResult{
+		{Keys: []string{"111", "Amazon EC2"}, Amount: 10, Periods: []PeriodCost{{Amount: 4}, {Amount: 6}}},
+		{Keys: []string{"333", "Amazon EC2"}, Amount: 15, Periods: []PeriodCost{{Amount: 15}, {Amount: 0}}},
+		{Keys: []string{"222", "Amazon EC2"}, Amount: 8, Periods: []PeriodCost{{Amount: 3}, {Amount: 5}}},
+		{Keys: []string{"444", "Amazon EC2"}, Amount: 1, Periods: []PeriodCost{{Amount: 1}, {Amount: 0}}},
+	}
+
+	rolled := accounts.RollUp(results, 0, "team")
+
+	if len(rolled) != 3 {
+		t.Fatalf("length: got %d, want 3", len(rolled))
+	}
+	if rolled[0].Keys[0] != "payments" || rolled[0].Amount != 18 || rolled[0].Periods[1].Amount != 11 {
+		t.Errorf("payments: got %+v", rolled[0])
+	}
+	if rolled[2].Keys[0] != Untagged {
+		t.Errorf("untagged: got %+v", rolled[2])
+	}
+	if results[0].Keys[0] != "111" || results[0].Periods[1].Amount != 6 {
+		t.Errorf("input modified: got %+v", results[0])
+	}
+}
+
+func TestAccountsAnnotate(t *testing.T) {
+	accounts := Accounts{"111": {Name: "prod"}, "staging": {Name: "staging"}}
+	results := []CostResult{
+		{Keys: []string{"111"}},
+		{Keys: []string{"999"}},
+	}
+
+	accounts.Annotate(results, 0)
+	if results[0].AccountInfo == nil || results[0].AccountInfo.Name != "prod" {
+		t.Errorf("first: got %+v", results[0].AccountInfo)
+	}
+	if results[1].AccountInfo != nil {
+		t.Errorf("unknown account: got %+v", results[1].AccountInfo)
+	}
+
+	byField := []CostResult{{Account: "staging", Keys: []string{"Amazon S3"}}}
+	accounts.Annotate(byField, -1)
+	if byField[0].AccountInfo == nil || byField[0].AccountInfo.Name != "staging" {
+		t.Errorf("account field: got %+v", byField[0].AccountInfo)
+	}
+}
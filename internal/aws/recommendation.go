@@ -0,0 +1,233 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+// DefaultReservationService is the service Reserved Instance recommendations
+// are requested for when none is given
+const DefaultReservationService = "Amazon Elastic Compute Cloud - Compute"
+
+// RecommendationQuery selects Savings Plans or Reserved Instance purchase
+// recommendations. SavingsPlansType only applies to Savings Plans and Service
+// only to Reserved Instances.
+type RecommendationQuery struct {
+	Term             types.TermInYears
+	Payment          types.PaymentOption
+	Lookback         types.LookbackPeriodInDays
+	SavingsPlansType types.SupportedSavingsPlansType
+	Service          string
+	Filter           *types.Expression
+}
+
+// Recommendation is a suggested commitment purchase. Resource is the instance
+// family for Savings Plans and the instance or node type for Reserved
+// Instances.
+type Recommendation struct {
+	Account          string  `json:"account,omitempty"`
+	Resource         string  `json:"resource"`
+	Region           string  `json:"region,omitempty"`
+	Quantity         float64 `json:"quantity,omitempty"`
+	HourlyCommitment float64 `json:"hourly_commitment,omitempty"`
+	UpfrontCost      float64 `json:"upfront_cost"`
+	MonthlySavings   float64 `json:"monthly_savings"`
+	SavingsPercent   float64 `json:"savings_percent"`
+	BreakEvenMonths  float64 `json:"break_even_months"`
+	Unit             string  `json:"unit"`
+}
+
+// GetSavingsPlansRecommendations returns Savings Plans purchase
+// recommendations for q, largest monthly savings first
+func (c *Client) GetSavingsPlansRecommendations(ctx context.Context, q RecommendationQuery) ([]Recommendation, error) {
+	var recs []Recommendation
+	var token *string
+
+	for {
+		output, err := c.ce.GetSavingsPlansPurchaseRecommendation(ctx, &costexplorer.GetSavingsPlansPurchaseRecommendationInput{
+			TermInYears:          q.Term,
+			PaymentOption:        q.Payment,
+			LookbackPeriodInDays: q.Lookback,
+			SavingsPlansType:     q.SavingsPlansType,
+			Filter:               q.Filter,
+			NextPageToken:        token,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if output.SavingsPlansPurchaseRecommendation != nil {
+			for _, d := range output.SavingsPlansPurchaseRecommendation.SavingsPlansPurchaseRecommendationDetails {
+				recs = append(recs, savingsPlansRecommendation(d, q.SavingsPlansType))
+			}
+		}
+
+		if aws.ToString(output.NextPageToken) == "" {
+			break
+		}
+		token = output.NextPageToken
+	}
+
+	sortByAmount(recs, func(r Recommendation) float64 { return r.MonthlySavings })
+	return recs, nil
+}
+
+// GetReservationRecommendations returns Reserved Instance purchase
+// recommendations for q, largest monthly savings first
+func (c *Client) GetReservationRecommendations(ctx context.Context, q RecommendationQuery) ([]Recommendation, error) {
+	service := q.Service
+	if service == "" {
+		service = DefaultReservationService
+	}
+
+	var recs []Recommendation
+	var token *string
+
+	for {
+		output, err := c.ce.GetReservationPurchaseRecommendation(ctx, &costexplorer.GetReservationPurchaseRecommendationInput{
+			Service:              aws.String(service),
+			TermInYears:          q.Term,
+			PaymentOption:        q.Payment,
+			LookbackPeriodInDays: q.Lookback,
+			Filter:               q.Filter,
+			NextPageToken:        token,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range output.Recommendations {
+			for _, d := range r.RecommendationDetails {
+				recs = append(recs, reservationRecommendation(d))
+			}
+		}
+
+		if aws.ToString(output.NextPageToken) == "" {
+			break
+		}
+		token = output.NextPageToken
+	}
+
+	sortByAmount(recs, func(r Recommendation) float64 { return r.MonthlySavings })
+	return recs, nil
+}
+
+// ParseTerm converts a commitment term (1y, 3y) into its Cost Explorer value
+func ParseTerm(s string) (types.TermInYears, error) {
+	switch strings.ToLower(s) {
+	case "1y", "1", "one-year", "":
+		return types.TermInYearsOneYear, nil
+	case "3y", "3", "three-years":
+		return types.TermInYearsThreeYears, nil
+	}
+	return "", fmt.Errorf("unknown term %q (want 1y or 3y)", s)
+}
+
+// ParsePaymentOption converts a payment option (no-upfront, partial-upfront,
+// all-upfront) into its Cost Explorer value
+func ParsePaymentOption(s string) (types.PaymentOption, error) {
+	switch strings.ToLower(s) {
+	case "no-upfront", "":
+		return types.PaymentOptionNoUpfront, nil
+	case "partial-upfront":
+		return types.PaymentOptionPartialUpfront, nil
+	case "all-upfront":
+		return types.PaymentOptionAllUpfront, nil
+	}
+	return "", fmt.Errorf("unknown payment option %q (want no-upfront, partial-upfront or all-upfront)", s)
+}
+
+// ParseLookback converts a lookback in days (7, 30, 60) into its Cost
+// Explorer value
+func ParseLookback(days int) (types.LookbackPeriodInDays, error) {
+	switch days {
+	case 7:
+		return types.LookbackPeriodInDaysSevenDays, nil
+	case 30:
+		return types.LookbackPeriodInDaysThirtyDays, nil
+	case 60:
+		return types.LookbackPeriodInDaysSixtyDays, nil
+	}
+	return "", fmt.Errorf("unsupported lookback %d days (want 7, 30 or 60)", days)
+}
+
+// ParseSavingsPlansType converts a plan type (compute, ec2-instance,
+// sagemaker, database) into its Cost Explorer value
+func ParseSavingsPlansType(s string) (types.SupportedSavingsPlansType, error) {
+	if s == "" {
+		return types.SupportedSavingsPlansTypeComputeSp, nil
+	}
+	value := types.SupportedSavingsPlansType(strings.ToUpper(strings.ReplaceAll(s, "-", "_")) + "_SP")
+	for _, t := range value.Values() {
+		if t == value {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown savings plans type %q (want compute, ec2-instance, sagemaker or database)", s)
+}
+
+func savingsPlansRecommendation(d types.SavingsPlansPurchaseRecommendationDetail, planType types.SupportedSavingsPlansType) Recommendation {
+	rec := Recommendation{
+		Account:          aws.ToString(d.AccountId),
+		Resource:         strings.ToLower(strings.TrimSuffix(string(planType), "_SP")),
+		HourlyCommitment: parseAmount(d.HourlyCommitmentToPurchase),
+		UpfrontCost:      parseAmount(d.UpfrontCost),
+		MonthlySavings:   parseAmount(d.EstimatedMonthlySavingsAmount),
+		SavingsPercent:   parseAmount(d.EstimatedSavingsPercentage),
+		Unit:             aws.ToString(d.CurrencyCode),
+	}
+	if sp := d.SavingsPlansDetails; sp != nil {
+		if family := aws.ToString(sp.InstanceFamily); family != "" {
+			rec.Resource = family
+		}
+		rec.Region = aws.ToString(sp.Region)
+	}
+	// Cost Explorer only reports break-even for reservations, so derive it
+	// from the upfront payment
+	if rec.UpfrontCost > 0 && rec.MonthlySavings > 0 {
+		rec.BreakEvenMonths = rec.UpfrontCost / rec.MonthlySavings
+	}
+	return rec
+}
+
+func reservationRecommendation(d types.ReservationPurchaseRecommendationDetail) Recommendation {
+	rec := Recommendation{
+		Account:         aws.ToString(d.AccountId),
+		Quantity:        parseAmount(d.RecommendedNumberOfInstancesToPurchase),
+		UpfrontCost:     parseAmount(d.UpfrontCost),
+		MonthlySavings:  parseAmount(d.EstimatedMonthlySavingsAmount),
+		SavingsPercent:  parseAmount(d.EstimatedMonthlySavingsPercentage),
+		BreakEvenMonths: parseAmount(d.EstimatedBreakEvenInMonths),
+		Unit:            aws.ToString(d.CurrencyCode),
+	}
+	rec.Resource, rec.Region = instanceDetails(d.InstanceDetails)
+	return rec
+}
+
+// instanceDetails returns the instance or node type and region of whichever
+// service the reservation is for
+func instanceDetails(d *types.InstanceDetails) (string, string) {
+	switch {
+	case d == nil:
+		return "", ""
+	case d.EC2InstanceDetails != nil:
+		return aws.ToString(d.EC2InstanceDetails.InstanceType), aws.ToString(d.EC2InstanceDetails.Region)
+	case d.RDSInstanceDetails != nil:
+		return aws.ToString(d.RDSInstanceDetails.InstanceType), aws.ToString(d.RDSInstanceDetails.Region)
+	case d.ElastiCacheInstanceDetails != nil:
+		return aws.ToString(d.ElastiCacheInstanceDetails.NodeType), aws.ToString(d.ElastiCacheInstanceDetails.Region)
+	case d.MemoryDBInstanceDetails != nil:
+		return aws.ToString(d.MemoryDBInstanceDetails.NodeType), aws.ToString(d.MemoryDBInstanceDetails.Region)
+	case d.RedshiftInstanceDetails != nil:
+		return aws.ToString(d.RedshiftInstanceDetails.NodeType), aws.ToString(d.RedshiftInstanceDetails.Region)
+	case d.ESInstanceDetails != nil:
+		es := d.ESInstanceDetails
+		return aws.ToString(es.InstanceClass) + "." + aws.ToString(es.InstanceSize), aws.ToString(es.Region)
+	}
+	return "", ""
+}
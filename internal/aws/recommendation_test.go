@@ -0,0 +1,153 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+func TestGetSavingsPlansRecommendations(t *testing.T) {
+	mock := &mockCostExplorer{
+		spRecommendation: &costexplorer.GetSavingsPlansPurchaseRecommendationOutput{
+			SavingsPlansPurchaseRecommendation: &types.SavingsPlansPurchaseRecommendation{
+				SavingsPlansPurchaseRecommendationDetails: []types.SavingsPlansPurchaseRecommendationDetail{
+					{
+						AccountId:                     aws.String("111111111111"),
+						HourlyCommitmentToPurchase:    aws.String("0.5"),
+						EstimatedMonthlySavingsAmount: aws.String("40"),
+						EstimatedSavingsPercentage:    aws.String("20"),
+						UpfrontCost:                   aws.String("0"),
+						CurrencyCode:                  aws.String("USD"),
+					},
+					{
+						AccountId:                     aws.String("222222222222"),
+						HourlyCommitmentToPurchase:    aws.String("1.2"),
+						EstimatedMonthlySavingsAmount: aws.String("150"),
+						EstimatedSavingsPercentage:    aws.String("30"),
+						UpfrontCost:                   aws.String("600"),
+						CurrencyCode:                  aws.String("USD"),
+						SavingsPlansDetails: &types.SavingsPlansDetails{
+							InstanceFamily: aws.String("m5"),
+							Region:         aws.String("us-east-1"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	recs, err := NewClientWithAPI(mock).GetSavingsPlansRecommendations(context.Background(), RecommendationQuery{
+		SavingsPlansType: types.SupportedSavingsPlansTypeComputeSp,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(recs) != 2 {
+		t.Fatalf("length: got %d, want 2", len(recs))
+	}
+	first := recs[0]
+	if first.Account != "222222222222" || first.Resource != "m5" || first.Region != "us-east-1" {
+		t.Errorf("first: got %+v", first)
+	}
+	if first.BreakEvenMonths != 4 {
+		t.Errorf("break even: got %.2f, want 4", first.BreakEvenMonths)
+	}
+	if recs[1].Resource != "compute" || recs[1].BreakEvenMonths != 0 {
+		t.Errorf("second: got %+v", recs[1])
+	}
+}
+
+func TestGetReservationRecommendations(t *testing.T) {
+	mock := &mockCostExplorer{
+		riRecommendation: &costexplorer.GetReservationPurchaseRecommendationOutput{
+			Recommendations: []types.ReservationPurchaseRecommendation{
+				{
+					RecommendationDetails: []types.ReservationPurchaseRecommendationDetail{
+						{
+							AccountId:                              aws.String("111111111111"),
+							RecommendedNumberOfInstancesToPurchase: aws.String("3"),
+							UpfrontCost:                            aws.String("1200"),
+							EstimatedMonthlySavingsAmount:          aws.String("90"),
+							EstimatedMonthlySavingsPercentage:      aws.String("35"),
+							EstimatedBreakEvenInMonths:             aws.String("13.3"),
+							InstanceDetails: &types.InstanceDetails{
+								EC2InstanceDetails: &types.EC2InstanceDetails{
+									InstanceType: aws.String("c5.xlarge"),
+									Region:       aws.String("eu-west-1"),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	recs, err := NewClientWithAPI(mock).GetReservationRecommendations(context.Background(), RecommendationQuery{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := aws.ToString(mock.riRecommendCalls[0].Service); got != DefaultReservationService {
+		t.Errorf("service: got %q, want %q", got, DefaultReservationService)
+	}
+	if len(recs) != 1 {
+		t.Fatalf("length: got %d, want 1", len(recs))
+	}
+	rec := recs[0]
+	if rec.Resource != "c5.xlarge" || rec.Region != "eu-west-1" || rec.Quantity != 3 || rec.BreakEvenMonths != 13.3 {
+		t.Errorf("recommendation: got %+v", rec)
+	}
+}
+
+func TestGetRecommendationsError(t *testing.T) {
+	client := NewClientWithAPI(&mockCostExplorer{err: errors.New("api error")})
+	if _, err := client.GetSavingsPlansRecommendations(context.Background(), RecommendationQuery{}); err == nil {
+		t.Error("savings plans: expected error, got nil")
+	}
+	if _, err := client.GetReservationRecommendations(context.Background(), RecommendationQuery{}); err == nil {
+		t.Error("reservations: expected error, got nil")
+	}
+}
+
+func TestParseRecommendationOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func() (string, error)
+		want    string
+		wantErr bool
+	}{
+		{name: "term 3y", parse: func() (string, error) { v, err := ParseTerm("3y"); return string(v), err }, want: "THREE_YEARS"},
+		{name: "term default", parse: func() (string, error) { v, err := ParseTerm(""); return string(v), err }, want: "ONE_YEAR"},
+		{name: "term invalid", parse: func() (string, error) { v, err := ParseTerm("5y"); return string(v), err }, wantErr: true},
+		{name: "payment", parse: func() (string, error) { v, err := ParsePaymentOption("partial-upfront"); return string(v), err }, want: "PARTIAL_UPFRONT"},
+		{name: "payment invalid", parse: func() (string, error) { v, err := ParsePaymentOption("later"); return string(v), err }, wantErr: true},
+		{name: "lookback", parse: func() (string, error) { v, err := ParseLookback(60); return string(v), err }, want: "SIXTY_DAYS"},
+		{name: "lookback invalid", parse: func() (string, error) { v, err := ParseLookback(14); return string(v), err }, wantErr: true},
+		{name: "plan type", parse: func() (string, error) { v, err := ParseSavingsPlansType("ec2-instance"); return string(v), err }, want: "EC2_INSTANCE_SP"},
+		{name: "plan type invalid", parse: func() (string, error) { v, err := ParseSavingsPlansType("lambda"); return string(v), err }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.parse()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("got %s, want %s", result, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+// rightsizingService is the only service Cost Explorer rightsizes
+const rightsizingService = "AmazonEC2"
+
+// RightsizingQuery selects EC2 rightsizing recommendations. CrossFamily
+// allows targets outside the current instance family and Benefits accounts
+// for Reserved Instance and Savings Plans discounts in the savings estimate.
+type RightsizingQuery struct {
+	CrossFamily bool
+	Benefits    bool
+	Filter      *types.Expression
+}
+
+// Rightsizing is a suggestion to terminate or resize an EC2 instance.
+// TargetType is empty for terminations.
+type Rightsizing struct {
+	Account        string  `json:"account"`
+	InstanceID     string  `json:"instance_id"`
+	InstanceName   string  `json:"instance_name,omitempty"`
+	Action         string  `json:"action"`
+	Region         string  `json:"region,omitempty"`
+	CurrentType    string  `json:"current_type"`
+	TargetType     string  `json:"target_type,omitempty"`
+	MonthlyCost    float64 `json:"monthly_cost"`
+	MonthlySavings float64 `json:"monthly_savings"`
+	MaxCPU         float64 `json:"max_cpu_percent"`
+	MaxMemory      float64 `json:"max_memory_percent,omitempty"`
+	Unit           string  `json:"unit"`
+}
+
+// GetRightsizing returns EC2 rightsizing recommendations for q, largest
+// monthly savings first
+func (c *Client) GetRightsizing(ctx context.Context, q RightsizingQuery) ([]Rightsizing, error) {
+	var recs []Rightsizing
+	var token *string
+
+	for {
+		output, err := c.ce.GetRightsizingRecommendation(ctx, &costexplorer.GetRightsizingRecommendationInput{
+			Service:       aws.String(rightsizingService),
+			Configuration: q.configuration(),
+			Filter:        q.Filter,
+			NextPageToken: token,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range output.RightsizingRecommendations {
+			recs = append(recs, parseRightsizing(r))
+		}
+
+		if aws.ToString(output.NextPageToken) == "" {
+			break
+		}
+		token = output.NextPageToken
+	}
+
+	sortByAmount(recs, func(r Rightsizing) float64 { return r.MonthlySavings })
+	return recs, nil
+}
+
+func (q RightsizingQuery) configuration() *types.RightsizingRecommendationConfiguration {
+	target := types.RecommendationTargetSameInstanceFamily
+	if q.CrossFamily {
+		target = types.RecommendationTargetCrossInstanceFamily
+	}
+	return &types.RightsizingRecommendationConfiguration{
+		RecommendationTarget: target,
+		BenefitsConsidered:   q.Benefits,
+	}
+}
+
+// RightsizingSortKeys are the values accepted by SortRightsizing
+var RightsizingSortKeys = []string{"savings", "cost", "cpu"}
+
+// CheckRightsizingSort returns an error if key is not one of
+// RightsizingSortKeys, so it can be checked before any paid request
+func CheckRightsizingSort(key string) error {
+	_, err := rightsizingSortValue(key)
+	return err
+}
+
+// SortRightsizing sorts recs by monthly savings, current monthly cost or
+// maximum CPU utilization, highest first
+func SortRightsizing(recs []Rightsizing, key string) error {
+	value, err := rightsizingSortValue(key)
+	if err != nil {
+		return err
+	}
+	sortByAmount(recs, value)
+	return nil
+}
+
+// rightsizingSortValue returns the value recommendations are sorted by for key
+func rightsizingSortValue(key string) (func(Rightsizing) float64, error) {
+	switch strings.ToLower(key) {
+	case "savings", "":
+		return func(r Rightsizing) float64 { return r.MonthlySavings }, nil
+	case "cost":
+		return func(r Rightsizing) float64 { return r.MonthlyCost }, nil
+	case "cpu":
+		return func(r Rightsizing) float64 { return r.MaxCPU }, nil
+	}
+	return nil, fmt.Errorf("unknown sort key %q (want %s)", key, strings.Join(RightsizingSortKeys, ", "))
+}
+
+func parseRightsizing(r types.RightsizingRecommendation) Rightsizing {
+	rec := Rightsizing{
+		Account: aws.ToString(r.AccountId),
+		Action:  strings.ToLower(string(r.RightsizingType)),
+	}
+
+	if cur := r.CurrentInstance; cur != nil {
+		rec.InstanceID = aws.ToString(cur.ResourceId)
+		rec.InstanceName = aws.ToString(cur.InstanceName)
+		rec.MonthlyCost = parseAmount(cur.MonthlyCost)
+		rec.Unit = aws.ToString(cur.CurrencyCode)
+		if d := ec2Details(cur.ResourceDetails); d != nil {
+			rec.CurrentType = aws.ToString(d.InstanceType)
+			rec.Region = aws.ToString(d.Region)
+		}
+		if cur.ResourceUtilization != nil && cur.ResourceUtilization.EC2ResourceUtilization != nil {
+			u := cur.ResourceUtilization.EC2ResourceUtilization
+			rec.MaxCPU = parseAmount(u.MaxCpuUtilizationPercentage)
+			rec.MaxMemory = parseAmount(u.MaxMemoryUtilizationPercentage)
+		}
+	}
+
+	switch {
+	case r.TerminateRecommendationDetail != nil:
+		rec.MonthlySavings = parseAmount(r.TerminateRecommendationDetail.EstimatedMonthlySavings)
+	case r.ModifyRecommendationDetail != nil:
+		if target := defaultTarget(r.ModifyRecommendationDetail.TargetInstances); target != nil {
+			rec.MonthlySavings = parseAmount(target.EstimatedMonthlySavings)
+			if d := ec2Details(target.ResourceDetails); d != nil {
+				rec.TargetType = aws.ToString(d.InstanceType)
+			}
+		}
+	}
+	return rec
+}
+
+// defaultTarget returns the target Cost Explorer marks as its default, or the
+// first one if none is marked
+func defaultTarget(targets []types.TargetInstance) *types.TargetInstance {
+	for i := range targets {
+		if targets[i].DefaultTargetInstance {
+			return &targets[i]
+		}
+	}
+	if len(targets) > 0 {
+		return &targets[0]
+	}
+	return nil
+}
+
+func ec2Details(d *types.ResourceDetails) *types.EC2ResourceDetails {
+	if d == nil {
+		return nil
+	}
+	return d.EC2ResourceDetails
+}
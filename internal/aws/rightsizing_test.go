@@ -0,0 +1,136 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+func TestGetRightsizing(t *testing.T) {
+	mock := &mockCostExplorer{
+		rightsizing: &costexplorer.GetRightsizingRecommendationOutput{
+			RightsizingRecommendations: []types.RightsizingRecommendation{
+				{
+					AccountId:       aws.String("111111111111"),
+					RightsizingType: types.RightsizingTypeModify,
+					CurrentInstance: &types.CurrentInstance{
+						ResourceId:   aws.String("i-modify"),
+						MonthlyCost:  aws.String("140"),
+						CurrencyCode: aws.String("USD"),
+						ResourceDetails: &types.ResourceDetails{
+							EC2ResourceDetails: &types.EC2ResourceDetails{InstanceType: aws.String("m5.2xlarge"), Region: aws.String("us-east-1")},
+						},
+						ResourceUtilization: &types.ResourceUtilization{
+							EC2ResourceUtilization: &types.EC2ResourceUtilization{MaxCpuUtilizationPercentage: aws.String("12.5")},
+						},
+					},
+					ModifyRecommendationDetail: &types.ModifyRecommendationDetail{
+						TargetInstances: []types.TargetInstance{
+							{
+								EstimatedMonthlySavings: aws.String("35"),
+								ResourceDetails:         &types.ResourceDetails{EC2ResourceDetails: &types.EC2ResourceDetails{InstanceType: aws.String("m5.large")}},
+							},
+							{
+								DefaultTargetInstance:   true,
+								EstimatedMonthlySavings: aws.String("70"),
+								ResourceDetails:         &types.ResourceDetails{EC2ResourceDetails: &types.EC2ResourceDetails{InstanceType: aws.String("m5.xlarge")}},
+							},
+						},
+					},
+				},
+				{
+					AccountId:       aws.String("111111111111"),
+					RightsizingType: types.RightsizingTypeTerminate,
+					CurrentInstance: &types.CurrentInstance{
+						ResourceId:  aws.String("i-idle"),
+						MonthlyCost: aws.String("280"),
+					},
+					TerminateRecommendationDetail: &types.TerminateRecommendationDetail{EstimatedMonthlySavings: aws.String("280")},
+				},
+			},
+		},
+	}
+
+	recs, err := NewClientWithAPI(mock).GetRightsizing(context.Background(), RightsizingQuery{CrossFamily: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	config := mock.rightsizingCalls[0].Configuration
+	if config.RecommendationTarget != types.RecommendationTargetCrossInstanceFamily {
+		t.Errorf("target: got %s, want CROSS_INSTANCE_FAMILY", config.RecommendationTarget)
+	}
+
+	if len(recs) != 2 {
+		t.Fatalf("length: got %d, want 2", len(recs))
+	}
+	if recs[0].InstanceID != "i-idle" || recs[0].Action != "terminate" || recs[0].MonthlySavings != 280 {
+		t.Errorf("first: got %+v", recs[0])
+	}
+	modify := recs[1]
+	if modify.CurrentType != "m5.2xlarge" || modify.TargetType != "m5.xlarge" || modify.MonthlySavings != 70 || modify.MaxCPU != 12.5 {
+		t.Errorf("modify: got %+v", modify)
+	}
+}
+
+func TestGetRightsizingError(t *testing.T) {
+	client := NewClientWithAPI(&mockCostExplorer{err: errors.New("api error")})
+	if _, err := client.GetRightsizing(context.Background(), RightsizingQuery{}); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestSortRightsizing(t *testing.T) {
+	recs := []Rightsizing{
+		{InstanceID: "a", MonthlySavings: 10, MonthlyCost: 300, MaxCPU: 5},
+		{InstanceID: "b", MonthlySavings: 50, MonthlyCost: 100, MaxCPU: 40},
+		{InstanceID: "c", MonthlySavings: 30, MonthlyCost: 200, MaxCPU: 20},
+	}
+
+	tests := []struct {
+		key      string
+		expected []string
+		wantErr  bool
+	}{
+		{key: "savings", expected: []string{"b", "c", "a"}},
+		{key: "cost", expected: []string{"a", "c", "b"}},
+		{key: "cpu", expected: []string{"b", "c", "a"}},
+		{key: "name", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			sorted := append([]Rightsizing(nil), recs...)
+			err := SortRightsizing(sorted, tt.key)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for i, id := range tt.expected {
+				if sorted[i].InstanceID != id {
+					t.Errorf("index %d: got %s, want %s", i, sorted[i].InstanceID, id)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckRightsizingSort(t *testing.T) {
+	for _, key := range append([]string{"", "Savings"}, RightsizingSortKeys...) {
+		if err := CheckRightsizingSort(key); err != nil {
+			t.Errorf("%q: unexpected error: %v", key, err)
+		}
+	}
+	if err := CheckRightsizingSort("name"); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
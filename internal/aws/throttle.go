@@ -0,0 +1,203 @@
+package aws
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultRequestsPerSecond keeps each account under the Cost Explorer
+	// request quota
+	DefaultRequestsPerSecond = 5
+
+	// DefaultMaxRetries is how often a throttled or failed request is retried
+	DefaultMaxRetries = 5
+
+	// CostPerRequest is what AWS charges per Cost Explorer API request, in USD
+	CostPerRequest = 0.01
+)
+
+// Backoff bounds between retries; each wait is drawn at random up to the
+// exponential delay for the attempt
+const (
+	baseBackoff = 500 * time.Millisecond
+	maxBackoff  = 20 * time.Second
+)
+
+// Throttle configures rate limiting and retries of Cost Explorer calls. A
+// RequestsPerSecond of zero disables rate limiting. Requests, when set, counts
+// every request sent, retries included.
+type Throttle struct {
+	RequestsPerSecond float64
+	MaxRetries        int
+	Requests          *RequestCounter
+}
+
+// DefaultThrottle returns the throttle used when none is configured
+func DefaultThrottle() Throttle {
+	return Throttle{RequestsPerSecond: DefaultRequestsPerSecond, MaxRetries: DefaultMaxRetries}
+}
+
+// RequestCounter counts Cost Explorer requests across clients
+type RequestCounter struct {
+	n atomic.Int64
+}
+
+// Count returns the number of requests sent
+func (c *RequestCounter) Count() int64 {
+	return c.n.Load()
+}
+
+// Cost returns what the counted requests cost, in USD
+func (c *RequestCounter) Cost() float64 {
+	return float64(c.Count()) * CostPerRequest
+}
+
+// isRetryable reports whether err is a throttling or transient error
+var isRetryable = retry.IsErrorRetryables(retry.DefaultRetryables)
+
+// throttledAPI rate limits and retries calls to a CostExplorerAPI
+type throttledAPI struct {
+	api        CostExplorerAPI
+	limiter    *rate.Limiter
+	maxRetries int
+	requests   *RequestCounter
+	sleep      func(context.Context, time.Duration) error
+}
+
+// NewThrottledAPI wraps api with a token-bucket rate limit and exponential
+// backoff with jitter on throttling and transient errors
+func NewThrottledAPI(api CostExplorerAPI, t Throttle) CostExplorerAPI {
+	limit := rate.Inf
+	if t.RequestsPerSecond > 0 {
+		limit = rate.Limit(t.RequestsPerSecond)
+	}
+	return &throttledAPI{
+		api:        api,
+		limiter:    rate.NewLimiter(limit, 1),
+		maxRetries: t.MaxRetries,
+		requests:   t.Requests,
+		sleep:      sleepContext,
+	}
+}
+
+// call runs fn once the rate limit allows, retrying retryable errors
+func call[O any](ctx context.Context, t *throttledAPI, fn func() (O, error)) (O, error) {
+	for attempt := 0; ; attempt++ {
+		var zero O
+		if err := t.limiter.Wait(ctx); err != nil {
+			return zero, err
+		}
+		if t.requests != nil {
+			t.requests.n.Add(1)
+		}
+
+		output, err := fn()
+		if err == nil || attempt >= t.maxRetries || isRetryable.IsErrorRetryable(err) != aws.TrueTernary {
+			return output, err
+		}
+		if err := t.sleep(ctx, backoff(attempt)); err != nil {
+			return zero, err
+		}
+	}
+}
+
+// backoff returns a random wait of up to baseBackoff doubled attempt times,
+// capped at maxBackoff
+func backoff(attempt int) time.Duration {
+	ceiling := maxBackoff
+	if attempt < 16 {
+		ceiling = min(maxBackoff, baseBackoff<<attempt)
+	}
+	return rand.N(ceiling) + 1
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (t *throttledAPI) GetCostAndUsage(ctx context.Context, params *costexplorer.GetCostAndUsageInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetCostAndUsageOutput, error) {
+	return call(ctx, t, func() (*costexplorer.GetCostAndUsageOutput, error) {
+		return t.api.GetCostAndUsage(ctx, params, optFns...)
+	})
+}
+
+func (t *throttledAPI) GetCostForecast(ctx context.Context, params *costexplorer.GetCostForecastInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetCostForecastOutput, error) {
+	return call(ctx, t, func() (*costexplorer.GetCostForecastOutput, error) {
+		return t.api.GetCostForecast(ctx, params, optFns...)
+	})
+}
+
+func (t *throttledAPI) GetAnomalies(ctx context.Context, params *costexplorer.GetAnomaliesInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetAnomaliesOutput, error) {
+	return call(ctx, t, func() (*costexplorer.GetAnomaliesOutput, error) {
+		return t.api.GetAnomalies(ctx, params, optFns...)
+	})
+}
+
+func (t *throttledAPI) GetSavingsPlansUtilization(ctx context.Context, params *costexplorer.GetSavingsPlansUtilizationInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetSavingsPlansUtilizationOutput, error) {
+	return call(ctx, t, func() (*costexplorer.GetSavingsPlansUtilizationOutput, error) {
+		return t.api.GetSavingsPlansUtilization(ctx, params, optFns...)
+	})
+}
+
+func (t *throttledAPI) GetSavingsPlansCoverage(ctx context.Context, params *costexplorer.GetSavingsPlansCoverageInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetSavingsPlansCoverageOutput, error) {
+	return call(ctx, t, func() (*costexplorer.GetSavingsPlansCoverageOutput, error) {
+		return t.api.GetSavingsPlansCoverage(ctx, params, optFns...)
+	})
+}
+
+func (t *throttledAPI) GetReservationUtilization(ctx context.Context, params *costexplorer.GetReservationUtilizationInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetReservationUtilizationOutput, error) {
+	return call(ctx, t, func() (*costexplorer.GetReservationUtilizationOutput, error) {
+		return t.api.GetReservationUtilization(ctx, params, optFns...)
+	})
+}
+
+func (t *throttledAPI) GetReservationCoverage(ctx context.Context, params *costexplorer.GetReservationCoverageInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetReservationCoverageOutput, error) {
+	return call(ctx, t, func() (*costexplorer.GetReservationCoverageOutput, error) {
+		return t.api.GetReservationCoverage(ctx, params, optFns...)
+	})
+}
+
+func (t *throttledAPI) GetSavingsPlansPurchaseRecommendation(ctx context.Context, params *costexplorer.GetSavingsPlansPurchaseRecommendationInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetSavingsPlansPurchaseRecommendationOutput, error) {
+	return call(ctx, t, func() (*costexplorer.GetSavingsPlansPurchaseRecommendationOutput, error) {
+		return t.api.GetSavingsPlansPurchaseRecommendation(ctx, params, optFns...)
+	})
+}
+
+func (t *throttledAPI) GetReservationPurchaseRecommendation(ctx context.Context, params *costexplorer.GetReservationPurchaseRecommendationInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetReservationPurchaseRecommendationOutput, error) {
+	return call(ctx, t, func() (*costexplorer.GetReservationPurchaseRecommendationOutput, error) {
+		return t.api.GetReservationPurchaseRecommendation(ctx, params, optFns...)
+	})
+}
+
+func (t *throttledAPI) GetRightsizingRecommendation(ctx context.Context, params *costexplorer.GetRightsizingRecommendationInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetRightsizingRecommendationOutput, error) {
+	return call(ctx, t, func() (*costexplorer.GetRightsizingRecommendationOutput, error) {
+		return t.api.GetRightsizingRecommendation(ctx, params, optFns...)
+	})
+}
+
+func (t *throttledAPI) GetDimensionValues(ctx context.Context, params *costexplorer.GetDimensionValuesInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetDimensionValuesOutput, error) {
+	return call(ctx, t, func() (*costexplorer.GetDimensionValuesOutput, error) {
+		return t.api.GetDimensionValues(ctx, params, optFns...)
+	})
+}
+
+func (t *throttledAPI) GetTags(ctx context.Context, params *costexplorer.GetTagsInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetTagsOutput, error) {
+	return call(ctx, t, func() (*costexplorer.GetTagsOutput, error) {
+		return t.api.GetTags(ctx, params, optFns...)
+	})
+}
@@ -0,0 +1,110 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/smithy-go"
+)
+
+// flakyCostExplorer fails GetCostAndUsage with errs, in order, before
+// answering from the embedded mock
+type flakyCostExplorer struct {
+	*mockCostExplorer
+	errs []error
+}
+
+func (f *flakyCostExplorer) GetCostAndUsage(ctx context.Context, params *costexplorer.GetCostAndUsageInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetCostAndUsageOutput, error) {
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	return f.mockCostExplorer.GetCostAndUsage(ctx, params, optFns...)
+}
+
+func TestThrottledAPIRetries(t *testing.T) {
+	throttled := &smithy.GenericAPIError{Code: "LimitExceededException", Message: "Rate exceeded"}
+	timeout := &smithy.GenericAPIError{Code: "RequestTimeoutException"}
+	denied := &smithy.GenericAPIError{Code: "AccessDeniedException"}
+
+	tests := []struct {
+		name       string
+		errs       []error
+		maxRetries int
+		wantErr    error
+		wantCount  int64
+		wantSleeps int
+	}{
+		{name: "success", wantCount: 1},
+		{name: "throttled then success", errs: []error{throttled, timeout}, maxRetries: 3, wantCount: 3, wantSleeps: 2},
+		{name: "retries exhausted", errs: []error{throttled, throttled, throttled}, maxRetries: 2, wantErr: throttled, wantCount: 3, wantSleeps: 2},
+		{name: "not retryable", errs: []error{denied}, maxRetries: 3, wantErr: denied, wantCount: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := &RequestCounter{}
+			api := NewThrottledAPI(&flakyCostExplorer{mockCostExplorer: &mockCostExplorer{output: &costexplorer.GetCostAndUsageOutput{}}, errs: tt.errs},
+				Throttle{MaxRetries: tt.maxRetries, Requests: counter}).(*throttledAPI)
+
+			var sleeps []time.Duration
+			api.sleep = func(ctx context.Context, d time.Duration) error {
+				sleeps = append(sleeps, d)
+				return nil
+			}
+
+			_, err := api.GetCostAndUsage(context.Background(), &costexplorer.GetCostAndUsageInput{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error: got %v, want %v", err, tt.wantErr)
+			}
+			if counter.Count() != tt.wantCount {
+				t.Errorf("requests: got %d, want %d", counter.Count(), tt.wantCount)
+			}
+			if len(sleeps) != tt.wantSleeps {
+				t.Errorf("sleeps: got %d, want %d", len(sleeps), tt.wantSleeps)
+			}
+		})
+	}
+}
+
+func TestThrottledAPICanceled(t *testing.T) {
+	api := NewThrottledAPI(&flakyCostExplorer{
+		mockCostExplorer: &mockCostExplorer{},
+		errs:             []error{&smithy.GenericAPIError{Code: "ThrottlingException"}},
+	}, Throttle{MaxRetries: 3}).(*throttledAPI)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	api.sleep = sleepContext
+	api.limiter.SetLimit(1000)
+
+	if _, err := api.GetCostAndUsage(ctx, &costexplorer.GetCostAndUsageInput{}); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 40; attempt++ {
+		ceiling := maxBackoff
+		if attempt < 5 {
+			ceiling = baseBackoff << attempt
+		}
+		for i := 0; i < 20; i++ {
+			if d := backoff(attempt); d <= 0 || d > ceiling {
+				t.Fatalf("attempt %d: got %v, want (0, %v]", attempt, d, ceiling)
+			}
+		}
+	}
+}
+
+func TestRequestCounterCost(t *testing.T) {
+	counter := &RequestCounter{}
+	counter.n.Add(42)
+	if got := counter.Cost(); got < 0.4199 || got > 0.4201 {
+		t.Errorf("cost: got %v, want 0.42", got)
+	}
+}
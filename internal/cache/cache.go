@@ -0,0 +1,161 @@
+// Package cache stores query responses on disk so repeated reports skip the
+// billing APIs. Entries are content-addressed by a hash of the full request.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultTTL is how long responses for periods that are still open are kept
+const DefaultTTL = 6 * time.Hour
+
+// Cache is an on-disk response cache. Responses for closed periods, which
+// end before yesterday, never expire; others expire after the TTL.
+type Cache struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+}
+
+// entry is the file format of a cached response. A zero Expires never
+// expires.
+type entry struct {
+	Expires time.Time       `json:"expires,omitzero"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// New creates a cache in dir
+func New(dir string, ttl time.Duration) *Cache {
+	return &Cache{dir: dir, ttl: ttl, now: time.Now}
+}
+
+// DefaultDir returns the cache directory under the user cache dir
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "dab-cloudcost"), nil
+}
+
+// Key hashes the parts of a request into a cache key
+func Key(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Closed reports whether a period ending at end, exclusive, is complete as of
+// now. Billing data keeps changing for about a day, so only periods ending
+// before yesterday are closed.
+func Closed(end, now time.Time) bool {
+	now = now.UTC()
+	yesterday := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, time.UTC)
+	return !end.After(yesterday)
+}
+
+// Get decodes the response stored under key into v, reporting whether it was
+// found. Expired and unreadable entries count as misses.
+func (c *Cache) Get(key string, v any) (bool, error) {
+	e, err := c.read(c.path(key))
+	if err != nil || c.expired(e) {
+		return false, nil
+	}
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+// Put stores v under key for a query of a period ending at end
+func (c *Cache) Put(key string, v any, end time.Time) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	e := entry{Data: data}
+	if !Closed(end, c.now()) {
+		e.Expires = c.now().Add(c.ttl)
+	}
+	content, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// write to a temporary file first so concurrent readers never see a
+	// partial entry
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Prune removes expired and unreadable entries, or every entry when all is
+// set, and returns how many were removed
+func (c *Cache) Prune(all bool) (int, error) {
+	removed := 0
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		if !all {
+			if e, err := c.read(path); err == nil && !c.expired(e) {
+				return nil
+			}
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// path spreads entries over subdirectories named after the key prefix
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+func (c *Cache) read(path string) (entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return entry{}, err
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return entry{}, err
+	}
+	return e, nil
+}
+
+func (c *Cache) expired(e entry) bool {
+	return !e.Expires.IsZero() && c.now().After(e.Expires)
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClosed(t *testing.T) {
+	now := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		end  time.Time
+		want bool
+	}{
+		{name: "last month", end: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), want: true},
+		{name: "ends yesterday", end: time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC), want: true},
+		{name: "includes yesterday", end: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), want: false},
+		{name: "future", end: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Closed(tt.end, now); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPut(t *testing.T) {
+	now := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	c := New(t.TempDir(), time.Hour)
+	c.now = func() time.Time { return now }
+
+	closed := Key("profile", "last month")
+	open := Key("profile", "this month")
+	if err := c.Put(closed, []string{"closed"}, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put(open, []string{"open"}, time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	if ok, _ := c.Get(open, &got); !ok || got[0] != "open" {
+		t.Errorf("open before ttl: got %v, %v", ok, got)
+	}
+	if ok, _ := c.Get(Key("other"), &got); ok {
+		t.Error("missing key: got hit")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if ok, _ := c.Get(open, &got); ok {
+		t.Error("open after ttl: got hit")
+	}
+
+	now = now.AddDate(1, 0, 0)
+	if ok, _ := c.Get(closed, &got); !ok || got[0] != "closed" {
+		t.Errorf("closed: got %v, %v", ok, got)
+	}
+}
+
+func TestGetCorrupt(t *testing.T) {
+	c := New(t.TempDir(), time.Hour)
+	key := Key("corrupt")
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	if ok, err := c.Get(key, &got); ok || err != nil {
+		t.Errorf("got %v, %v; want miss", ok, err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	c := New(t.TempDir(), time.Hour)
+	c.now = func() time.Time { return now }
+
+	c.Put(Key("closed"), 1, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	c.Put(Key("open"), 2, time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC))
+	now = now.Add(2 * time.Hour)
+
+	removed, err := c.Prune(false)
+	if err != nil || removed != 1 {
+		t.Fatalf("expired: got %d, %v; want 1", removed, err)
+	}
+	removed, err = c.Prune(true)
+	if err != nil || removed != 1 {
+		t.Fatalf("all: got %d, %v; want 1", removed, err)
+	}
+}
+
+func TestPruneMissingDir(t *testing.T) {
+	c := New(filepath.Join(t.TempDir(), "missing"), time.Hour)
+	if removed, err := c.Prune(true); err != nil || removed != 0 {
+		t.Errorf("got %d, %v; want 0, nil", removed, err)
+	}
+}
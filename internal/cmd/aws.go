@@ -6,17 +6,29 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/amayabdaniel/dab-cloudcost/internal/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
 	"github.com/spf13/cobra"
 )
 
 var (
-	awsDays    int
-	awsProfile string
-	awsOutput  string
-	awsTop     int
+	awsPeriod      periodFlags
+	awsProfiles    []string
+	awsRoleArns    []string
+	awsWorkers     int
+	awsRate        float64
+	awsMaxRetries  int
+	awsOutput      string
+	awsTop         int
+	awsByPeriod    bool
+	awsGranularity string
+	awsGroupBy     []string
+	awsMetrics     []string
+	awsFilters     []string
+	awsExcludes    []string
 )
 
 var awsCmd = &cobra.Command{
@@ -26,54 +38,294 @@ var awsCmd = &cobra.Command{
 	RunE:  runAWS,
 }
 
+// awsRequests counts the Cost Explorer requests made by this run, and
+// awsPages the cost and usage pages fetched, including cached ones
+var (
+	awsRequests aws.RequestCounter
+	awsPages    int
+)
+
+// reportAWSRequests prints the Cost Explorer page and request counts and the
+// request cost with --verbose
+func reportAWSRequests() {
+	if verbose && awsPages > 0 {
+		fmt.Fprintf(os.Stderr, "fetched %d page(s) from cost explorer\n", awsPages)
+	}
+	if verbose && awsRequests.Count() > 0 {
+		fmt.Fprintf(os.Stderr, "made %d cost explorer request(s), costing $%.2f\n", awsRequests.Count(), awsRequests.Cost())
+	}
+}
+
 func runAWS(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 
-	fmt.Printf("fetching aws costs for last %d days...\n\n", awsDays)
+	window, err := awsPeriod.resolve(cmd)
+	if err != nil {
+		return err
+	}
+
+	granularity, err := aws.ParseGranularity(awsGranularity)
+	if err != nil {
+		return err
+	}
+	series := awsByPeriod || granularity != types.GranularityMonthly
+
+	accountGroups, err := parseAWSGroupBys(awsGroupBy)
+	if err != nil {
+		return err
+	}
+
+	metrics, err := aws.ParseMetrics(awsMetrics)
+	if err != nil {
+		return err
+	}
 
-	client, err := aws.NewClient(ctx, awsProfile)
+	filter, err := awsFilterExpression()
 	if err != nil {
-		return fmt.Errorf("failed to create aws client: %w", err)
+		return err
 	}
 
-	costs, err := client.GetCostsByService(ctx, awsDays)
+	targets, err := awsTargets()
 	if err != nil {
-		return fmt.Errorf("failed to get costs: %w", err)
+		return err
+	}
+
+	q := aws.CostQuery{
+		Period:      window,
+		Granularity: granularity,
+		GroupBy:     accountGroups.groupBy,
+		Metrics:     metrics,
+		Filter:      filter,
+	}
+
+	var costs []aws.CostResult
+	var pages int
+	if len(targets) == 1 {
+		fmt.Printf("fetching aws costs for %s...\n\n", window)
+
+		client, err := newAWSTargetClient(ctx, targets[0])
+		if err != nil {
+			return fmt.Errorf("failed to create aws client: %w", err)
+		}
+		costs, pages, err = client.GetCosts(ctx, q)
+		if err != nil {
+			return fmt.Errorf("failed to get costs: %w", err)
+		}
+	} else {
+		fmt.Printf("fetching aws costs for %s across %d accounts...\n\n", window, len(targets))
+
+		accounts := make([]aws.AccountClient, len(targets))
+		for i, t := range targets {
+			account, err := resolveAWSAccount(ctx, t)
+			if err != nil {
+				return err
+			}
+			client, err := newAWSTargetClient(ctx, t)
+			if err != nil {
+				return fmt.Errorf("failed to create aws client for %s: %w", account, err)
+			}
+			accounts[i] = aws.AccountClient{Account: account, Client: client}
+		}
+		costs, pages, err = aws.GetCostsForAccounts(ctx, accounts, awsWorkers, q)
+		if err != nil {
+			return fmt.Errorf("failed to get costs: %w", err)
+		}
 	}
+	awsPages += pages
 
 	if len(costs) == 0 {
 		fmt.Println("no cost data found")
 		return nil
 	}
 
+	costs, err = accountGroups.applyAccounts(ctx, costs)
+	if err != nil {
+		return err
+	}
+
+	return renderCosts(accountGroups.columns(), metrics, costs, series)
+}
+
+// renderCosts writes the top --top costs in the selected output format. In
+// series mode each group is broken down by period.
+func renderCosts(columns, metrics []string, costs []aws.CostResult, series bool) error {
 	if awsTop > 0 && awsTop < len(costs) {
 		costs = costs[:awsTop]
 	}
 
+	if !series {
+		for i := range costs {
+			costs[i].Periods = nil
+		}
+	}
+
+	if awsOutput == "json" {
+		// json carries the account and its metadata as fields of each group
+		if series {
+			return outputSeriesJSON(columns, metrics, costs)
+		}
+		return outputJSON(columns, costs)
+	}
+
+	columns, costs = accountColumns(columns, costs)
+	columns, costs = accountInfoColumns(columns, costs)
 	switch awsOutput {
-	case "json":
-		return outputJSON(costs)
 	case "csv":
-		return outputCSV(costs)
+		if series {
+			return outputSeriesCSV(columns, metrics, costs)
+		}
+		return outputCSV(columns, metrics, costs)
 	default:
-		return outputTable(costs)
+		return outputTable(columns, metrics, costs)
+	}
+}
+
+// accountColumns prepends an account column to the keys of multi-account
+// results, leaving single-account results unchanged
+func accountColumns(columns []string, costs []aws.CostResult) ([]string, []aws.CostResult) {
+	if len(costs) == 0 || costs[0].Account == "" {
+		return columns, costs
+	}
+	withAccount := make([]aws.CostResult, len(costs))
+	for i, c := range costs {
+		c.Keys = append([]string{c.Account}, c.Keys...)
+		withAccount[i] = c
+	}
+	return append([]string{"account"}, columns...), withAccount
+}
+
+// awsTargets returns the accounts selected with --profile and --role-arn.
+// Roles are assumed with the credentials of the first profile.
+func awsTargets() ([]aws.Target, error) {
+	if len(awsRoleArns) == 0 {
+		targets := make([]aws.Target, len(awsProfiles))
+		for i, p := range awsProfiles {
+			targets[i] = aws.Target{Profile: p}
+		}
+		return targets, nil
+	}
+
+	if len(awsProfiles) > 1 {
+		return nil, fmt.Errorf("--role-arn takes a single --profile to assume roles from")
 	}
+	targets := make([]aws.Target, len(awsRoleArns))
+	for i, arn := range awsRoleArns {
+		targets[i] = aws.Target{Profile: awsProfiles[0], RoleArn: arn}
+	}
+	return targets, nil
+}
+
+// awsThrottle returns the rate limit and retries set with --rate and
+// --max-retries
+func awsThrottle() aws.Throttle {
+	return aws.Throttle{
+		RequestsPerSecond: awsRate,
+		MaxRetries:        awsMaxRetries,
+		Requests:          &awsRequests,
+	}
+}
+
+// resolveAWSAccount returns the account ID of t, asking STS once for profile
+// targets
+func resolveAWSAccount(ctx context.Context, t aws.Target) (string, error) {
+	if t.RoleArn != "" {
+		return t.Account(), nil
+	}
+	api, err := aws.NewCallerIdentityClient(ctx, t)
+	if err != nil {
+		return "", fmt.Errorf("failed to create sts client for %s: %w", t.Profile, err)
+	}
+	return aws.ResolveAccount(ctx, t, api)
 }
 
-func outputJSON(costs []aws.CostResult) error {
+// newAWSTargetClient creates the client for t, throttled with awsThrottle and
+// cached unless --no-cache is set
+func newAWSTargetClient(ctx context.Context, t aws.Target) (*aws.Client, error) {
+	client, err := aws.NewClientForTarget(ctx, t, awsThrottle())
+	if err != nil {
+		return nil, err
+	}
+	return client.WithCache(openCache(), t.Profile+"|"+t.RoleArn), nil
+}
+
+// newAWSClient creates the client for commands that query a single account
+func newAWSClient(ctx context.Context) (*aws.Client, error) {
+	targets, err := awsTargets()
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) != 1 {
+		return nil, fmt.Errorf("this command queries a single account; pass one --profile or --role-arn")
+	}
+	return newAWSTargetClient(ctx, targets[0])
+}
+
+// metricColumns returns the amount columns: one per metric when several were
+// requested, otherwise a single cost column
+func metricColumns(metrics []string) []string {
+	if len(metrics) > 1 {
+		return metrics
+	}
+	return []string{"cost"}
+}
+
+// metricValues returns the amounts of c matching metricColumns
+func metricValues(metrics []string, c aws.CostResult) []float64 {
+	if len(metrics) <= 1 {
+		return []float64{c.Amount}
+	}
+	values := make([]float64, len(metrics))
+	for i, m := range metrics {
+		values[i] = c.Metrics[m]
+	}
+	return values
+}
+
+// addFilterFlags registers --filter and --exclude on an aws command
+func addFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&awsFilters, "filter", nil, "only include costs matching key=value[,value...], e.g. service=Amazon EC2 or tag:env=prod (repeatable)")
+	cmd.Flags().StringArrayVar(&awsExcludes, "exclude", nil, "exclude costs matching key=value[,value...], e.g. record-type=Credit,Refund,Tax (repeatable)")
+}
+
+// awsFilterExpression builds the Cost Explorer filter from --filter and
+// --exclude
+func awsFilterExpression() (*types.Expression, error) {
+	include, err := aws.ParseFilters(awsFilters)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := aws.ParseFilters(awsExcludes)
+	if err != nil {
+		return nil, err
+	}
+	return aws.BuildFilter(include, exclude), nil
+}
+
+// groupByColumns returns the column names for the keys of each result
+func groupByColumns(groupBy []aws.GroupBy) []string {
+	columns := make([]string, len(groupBy))
+	for i, g := range groupBy {
+		columns[i] = g.String()
+	}
+	return columns
+}
+
+func outputJSON(columns []string, costs []aws.CostResult) error {
 	var total float64
 	for _, c := range costs {
 		total += c.Amount
 	}
 
 	output := struct {
-		Services []aws.CostResult `json:"services"`
-		Total    float64          `json:"total"`
-		Unit     string           `json:"unit"`
+		GroupBy []string         `json:"group_by"`
+		Groups  []aws.CostResult `json:"groups"`
+		Total   float64          `json:"total"`
+		Unit    string           `json:"unit"`
 	}{
-		Services: costs,
-		Total:    total,
-		Unit:     costs[0].Unit,
+		GroupBy: columns,
+		Groups:  costs,
+		Total:   total,
+		Unit:    costs[0].Unit,
 	}
 
 	enc := json.NewEncoder(os.Stdout)
@@ -81,43 +333,155 @@ func outputJSON(costs []aws.CostResult) error {
 	return enc.Encode(output)
 }
 
-func outputCSV(costs []aws.CostResult) error {
+func outputCSV(columns, metrics []string, costs []aws.CostResult) error {
 	w := csv.NewWriter(os.Stdout)
-	w.Write([]string{"service", "cost", "unit"})
+	header := append(append([]string{}, columns...), metricColumns(metrics)...)
+	w.Write(append(header, "unit"))
 
-	var total float64
+	totals := make([]float64, len(metricColumns(metrics)))
 	for _, c := range costs {
-		w.Write([]string{c.Service, fmt.Sprintf("%.2f", c.Amount), c.Unit})
-		total += c.Amount
+		row := append([]string{}, c.Keys...)
+		for i, v := range metricValues(metrics, c) {
+			row = append(row, fmt.Sprintf("%.2f", v))
+			totals[i] += v
+		}
+		w.Write(append(row, c.Unit))
 	}
 
-	w.Write([]string{"TOTAL", fmt.Sprintf("%.2f", total), costs[0].Unit})
+	row := totalRow(len(columns))
+	for _, t := range totals {
+		row = append(row, fmt.Sprintf("%.2f", t))
+	}
+	w.Write(append(row, costs[0].Unit))
 	w.Flush()
 	return w.Error()
 }
 
-func outputTable(costs []aws.CostResult) error {
+func outputSeriesJSON(columns, metrics []string, costs []aws.CostResult) error {
+	var totals map[string]float64
+	if len(metrics) > 1 {
+		totals = make(map[string]float64, len(metrics))
+		for _, c := range costs {
+			for _, m := range metrics {
+				totals[m] += c.Metrics[m]
+			}
+		}
+	}
+
+	output := struct {
+		GroupBy []string              `json:"group_by"`
+		Metrics []string              `json:"metrics,omitempty"`
+		Series  []aws.TimeSeriesPoint `json:"series"`
+		Total   float64               `json:"total"`
+		Totals  map[string]float64    `json:"totals,omitempty"`
+		Unit    string                `json:"unit"`
+	}{
+		GroupBy: columns,
+		Series:  aws.TimeSeries(costs),
+		Total:   aws.TotalCost(costs),
+		Totals:  totals,
+		Unit:    costs[0].Unit,
+	}
+	if len(metrics) > 1 {
+		output.Metrics = metrics
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(output)
+}
+
+func outputSeriesCSV(columns, metrics []string, costs []aws.CostResult) error {
+	w := csv.NewWriter(os.Stdout)
+	header := append(append([]string{"start", "end"}, columns...), metricColumns(metrics)...)
+	w.Write(append(header, "unit"))
+
+	for _, p := range aws.TimeSeries(costs) {
+		row := append([]string{p.Start, p.End}, p.Keys...)
+		for _, v := range metricValues(metrics, aws.CostResult{Amount: p.Amount, Metrics: p.Metrics}) {
+			row = append(row, fmt.Sprintf("%.2f", v))
+		}
+		w.Write(append(row, p.Unit))
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func outputTable(columns, metrics []string, costs []aws.CostResult) error {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "SERVICE\tCOST\tUNIT")
-	fmt.Fprintln(w, "-------\t----\t----")
+	periods := costs[0].Periods
 
-	var total float64
+	var header, rule string
+	for _, c := range columns {
+		header += strings.ToUpper(c) + "\t"
+		rule += strings.Repeat("-", len(c)) + "\t"
+	}
+	for _, p := range periods {
+		header += p.Start + "\t"
+		rule += strings.Repeat("-", len(p.Start)) + "\t"
+	}
+	for _, m := range metricColumns(metrics) {
+		if len(metrics) <= 1 {
+			m = strings.ToUpper(m)
+		}
+		header += m + "\t"
+		rule += strings.Repeat("-", len(m)) + "\t"
+	}
+	fmt.Fprintln(w, header+"UNIT")
+	fmt.Fprintln(w, rule+"----")
+
+	totals := make([]float64, len(metricColumns(metrics)))
+	periodTotals := make([]float64, len(periods))
 	for _, c := range costs {
-		fmt.Fprintf(w, "%s\t%.2f\t%s\n", c.Service, c.Amount, c.Unit)
-		total += c.Amount
+		fmt.Fprint(w, strings.Join(c.Keys, "\t")+"\t")
+		for i, p := range c.Periods {
+			fmt.Fprintf(w, "%.2f\t", p.Amount)
+			periodTotals[i] += p.Amount
+		}
+		for i, v := range metricValues(metrics, c) {
+			fmt.Fprintf(w, "%.2f\t", v)
+			totals[i] += v
+		}
+		fmt.Fprintf(w, "%s\n", c.Unit)
 	}
 
-	fmt.Fprintln(w, "-------\t----\t----")
-	fmt.Fprintf(w, "TOTAL\t%.2f\t%s\n", total, costs[0].Unit)
+	fmt.Fprintln(w, rule+"----")
+	fmt.Fprint(w, strings.Join(totalRow(len(columns)), "\t")+"\t")
+	for _, t := range periodTotals {
+		fmt.Fprintf(w, "%.2f\t", t)
+	}
+	for _, t := range totals {
+		fmt.Fprintf(w, "%.2f\t", t)
+	}
+	fmt.Fprintf(w, "%s\n", costs[0].Unit)
 	w.Flush()
 
 	return nil
 }
 
+// totalRow returns the leading cells of a TOTAL row spanning n key columns
+func totalRow(n int) []string {
+	row := make([]string, n)
+	row[0] = "TOTAL"
+	return row
+}
+
 func init() {
-	awsCmd.Flags().IntVarP(&awsDays, "days", "d", 30, "number of days to analyze")
-	awsCmd.Flags().StringVarP(&awsProfile, "profile", "p", "default", "aws profile to use")
-	awsCmd.Flags().StringVarP(&awsOutput, "output", "o", "table", "output format (table, json, csv)")
-	awsCmd.Flags().IntVarP(&awsTop, "top", "t", 0, "show top N services (0 = all)")
+	awsPeriod.register(awsCmd, 30)
+	awsCmd.PersistentFlags().StringSliceVarP(&awsProfiles, "profile", "p", []string{"default"}, "aws profiles to use; several profiles query each account and add an account column")
+	awsCmd.PersistentFlags().StringSliceVar(&awsRoleArns, "role-arn", nil, "iam roles to assume from the profile, one per account to query")
+	awsCmd.PersistentFlags().StringVar(&awsAccountsFile, "accounts-file", "", "yaml or json file mapping account ids to a name, ou and tags, used where organizations is unavailable")
+	awsCmd.PersistentFlags().BoolVar(&awsOrg, "org", false, "resolve account names, ou paths and tags from aws organizations (needs the management account)")
+	awsCmd.PersistentFlags().Float64Var(&awsRate, "rate", aws.DefaultRequestsPerSecond, "max cost explorer requests per second per account (0 = unlimited)")
+	awsCmd.PersistentFlags().IntVar(&awsMaxRetries, "max-retries", aws.DefaultMaxRetries, "retries of throttled or failed cost explorer requests")
+	awsCmd.Flags().IntVar(&awsWorkers, "concurrency", aws.DefaultWorkers, "accounts to query at once")
+	awsCmd.PersistentFlags().StringVarP(&awsOutput, "output", "o", "table", "output format (table, json, csv)")
+	awsCmd.Flags().IntVarP(&awsTop, "top", "t", 0, "show top N groups (0 = all)")
+	awsCmd.Flags().BoolVar(&awsByPeriod, "by-period", false, "break costs down per period instead of only the total")
+	awsCmd.Flags().StringVarP(&awsGranularity, "granularity", "g", "monthly", "period size (daily, monthly, hourly); daily and hourly imply --by-period")
+	awsCmd.Flags().StringSliceVar(&awsGroupBy, "group-by", nil, "up to two dimensions to group by, e.g. service, linked-account, region, usage-type, operation, instance-type, tag:<key>, costcategory:<name>, account-tag:<key> (default service)")
+	awsCmd.Flags().StringSliceVarP(&awsMetrics, "metric", "m", nil, "cost metrics to report, e.g. unblended, amortized, blended, net-unblended, net-amortized, usage-quantity; the first drives sorting and totals (default unblended)")
+	addFilterFlags(awsCmd)
 	rootCmd.AddCommand(awsCmd)
 }
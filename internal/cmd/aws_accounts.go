@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+var (
+	awsAccountsFile string
+	awsOrg          bool
+)
+
+// accountGroupBy is a parsed --group-by where account-tag:<key> groups have
+// been replaced with LINKED_ACCOUNT and are rolled up after the query
+type accountGroupBy struct {
+	groupBy []aws.GroupBy
+	rollups map[int]string
+}
+
+// parseAWSGroupBys parses --group-by values, including account-tag:<key>
+func parseAWSGroupBys(values []string) (accountGroupBy, error) {
+	parsed := accountGroupBy{rollups: make(map[int]string)}
+	plain := make([]string, len(values))
+	for i, v := range values {
+		if len(v) > len(aws.AccountTagPrefix) && strings.EqualFold(v[:len(aws.AccountTagPrefix)], aws.AccountTagPrefix) {
+			parsed.rollups[i] = v[len(aws.AccountTagPrefix):]
+			plain[i] = "linked-account"
+			continue
+		}
+		plain[i] = v
+	}
+
+	groupBy, err := aws.ParseGroupBys(plain)
+	if err != nil {
+		return accountGroupBy{}, err
+	}
+	if len(parsed.rollups) > 1 || (len(parsed.rollups) > 0 && parsed.accountIndex(groupBy) >= 0) {
+		return accountGroupBy{}, fmt.Errorf("account-tag cannot be combined with linked-account or another account-tag")
+	}
+	if len(parsed.rollups) > 0 && !awsOrg && awsAccountsFile == "" {
+		return accountGroupBy{}, fmt.Errorf("account-tag needs account tags from --org or --accounts-file")
+	}
+	parsed.groupBy = groupBy
+	return parsed, nil
+}
+
+// accountIndex returns the position of a LINKED_ACCOUNT group that is not
+// rolled up, or -1
+func (a accountGroupBy) accountIndex(groupBy []aws.GroupBy) int {
+	for i, g := range groupBy {
+		if _, rolled := a.rollups[i]; rolled {
+			continue
+		}
+		if g.Type == types.GroupDefinitionTypeDimension && g.Key == string(types.DimensionLinkedAccount) {
+			return i
+		}
+	}
+	return -1
+}
+
+// columns returns the key column names, with rolled up groups named after
+// their account tag
+func (a accountGroupBy) columns() []string {
+	columns := groupByColumns(a.groupBy)
+	for i, key := range a.rollups {
+		columns[i] = aws.AccountTagPrefix + key
+	}
+	return columns
+}
+
+// applyAccounts rolls up account-tag groups and adds account metadata to
+// costs keyed by account. Account metadata is only resolved when needed.
+func (a accountGroupBy) applyAccounts(ctx context.Context, costs []aws.CostResult) ([]aws.CostResult, error) {
+	index := a.accountIndex(a.groupBy)
+	multiAccount := len(costs) > 0 && costs[0].Account != ""
+	if len(a.rollups) == 0 && index < 0 && !multiAccount {
+		return costs, nil
+	}
+	if !awsOrg && awsAccountsFile == "" {
+		return costs, nil
+	}
+
+	accounts, err := resolveAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, key := range a.rollups {
+		costs = accounts.RollUp(costs, i, key)
+	}
+	accounts.Annotate(costs, index)
+	return costs, nil
+}
+
+// resolveAccounts loads account metadata from AWS Organizations, when
+// enabled with --org, and the accounts file. Organizations errors only warn,
+// since only the management account can call it.
+func resolveAccounts(ctx context.Context) (aws.Accounts, error) {
+	accounts := aws.Accounts{}
+
+	if awsOrg {
+		org, err := listOrganizationAccounts(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: could not list organization accounts: %v\n", err)
+		} else {
+			accounts = org
+		}
+	}
+
+	if awsAccountsFile != "" {
+		file, err := aws.LoadAccountsFile(awsAccountsFile)
+		if err != nil {
+			return nil, err
+		}
+		accounts = accounts.Merge(file)
+	}
+	return accounts, nil
+}
+
+// listOrganizationAccounts lists accounts with the first --profile, which is
+// the management account when assuming member roles
+func listOrganizationAccounts(ctx context.Context) (aws.Accounts, error) {
+	api, err := aws.NewOrganizationsClient(ctx, aws.Target{Profile: awsProfiles[0]})
+	if err != nil {
+		return nil, err
+	}
+	return aws.ListOrganizationAccounts(ctx, api)
+}
+
+// accountInfoColumns appends account name, OU path and tag columns to the keys
+// of costs that have account metadata
+func accountInfoColumns(columns []string, costs []aws.CostResult) ([]string, []aws.CostResult) {
+	annotated := false
+	for _, c := range costs {
+		if c.AccountInfo != nil {
+			annotated = true
+			break
+		}
+	}
+	if !annotated {
+		return columns, costs
+	}
+
+	withInfo := make([]aws.CostResult, len(costs))
+	for i, c := range costs {
+		info := aws.AccountInfo{}
+		if c.AccountInfo != nil {
+			info = *c.AccountInfo
+		}
+		c.Keys = append(append([]string{}, c.Keys...), info.Name, info.OUPath, info.TagString())
+		withInfo[i] = c
+	}
+	return append(append([]string{}, columns...), "account_name", "ou_path", "account_tags"), withInfo
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import "testing"
+
+func TestParseAWSGroupBys(t *testing.T) {
+	awsAccountsFile = "accounts.yaml"
+	defer func() { awsAccountsFile = "" }()
+
+	tests := []struct {
+		name        string
+		input       []string
+		wantRollups int
+		wantErr     bool
+	}{
+		{name: "account tag", input: []string{"account-tag:team", "service"}, wantRollups: 1},
+		{name: "plain", input: []string{"linked-account", "region"}},
+		{name: "account tag and linked account", input: []string{"account-tag:team", "linked-account"}, wantErr: true},
+		{name: "two account tags", input: []string{"account-tag:team", "account-tag:env"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAWSGroupBys(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error: got %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got.rollups) != tt.wantRollups {
+				t.Errorf("rollups: got %v, want %d", got.rollups, tt.wantRollups)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/aws"
+	"github.com/spf13/cobra"
+)
+
+var (
+	anomaliesPeriod     periodFlags
+	anomaliesMonitorArn string
+	anomaliesMinImpact  float64
+	anomaliesFeedback   string
+)
+
+var awsAnomaliesCmd = &cobra.Command{
+	Use:   "anomalies",
+	Short: "List AWS cost anomalies",
+	Long: `List findings from AWS Cost Anomaly Detection for the selected window,
+with their root causes, impact and feedback status.`,
+	RunE: runAWSAnomalies,
+}
+
+func runAWSAnomalies(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	window, err := anomaliesPeriod.resolve(cmd)
+	if err != nil {
+		return err
+	}
+
+	feedback, err := aws.ParseFeedback(anomaliesFeedback)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("fetching aws cost anomalies for %s...\n\n", window)
+
+	client, err := newAWSClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create aws client: %w", err)
+	}
+
+	anomalies, err := client.GetAnomalies(ctx, aws.AnomalyQuery{
+		Period:     window,
+		MonitorArn: anomaliesMonitorArn,
+		MinImpact:  anomaliesMinImpact,
+		Feedback:   feedback,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get anomalies: %w", err)
+	}
+
+	if len(anomalies) == 0 {
+		fmt.Println("no anomalies found")
+		return nil
+	}
+
+	switch awsOutput {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(anomalies)
+	case "csv":
+		return anomaliesOutputCSV(anomalies)
+	default:
+		return anomaliesOutputTable(anomalies)
+	}
+}
+
+// anomalyRootCauses returns the root causes of a, or a single empty one so
+// anomalies without root causes still get a row
+func anomalyRootCauses(a aws.Anomaly) []aws.RootCause {
+	if len(a.RootCauses) == 0 {
+		return []aws.RootCause{{}}
+	}
+	return a.RootCauses
+}
+
+func anomaliesOutputCSV(anomalies []aws.Anomaly) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"id", "start", "end", "impact", "impact_percent", "feedback", "service", "account", "region", "usage_type", "contribution"})
+
+	for _, a := range anomalies {
+		for _, rc := range anomalyRootCauses(a) {
+			w.Write([]string{
+				a.ID, a.Start, a.End,
+				fmt.Sprintf("%.2f", a.Impact), fmt.Sprintf("%.2f", a.ImpactPercent), a.Feedback,
+				rc.Service, rc.Account, rc.Region, rc.UsageType, fmt.Sprintf("%.2f", rc.Contribution),
+			})
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func anomaliesOutputTable(anomalies []aws.Anomaly) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "START\tEND\tIMPACT\tIMPACT %\tFEEDBACK\tSERVICE\tACCOUNT\tREGION\tUSAGE TYPE")
+	fmt.Fprintln(w, "-----\t---\t------\t--------\t--------\t-------\t-------\t------\t----------")
+
+	var total float64
+	for _, a := range anomalies {
+		feedback := a.Feedback
+		if feedback == "" {
+			feedback = "-"
+		}
+		for i, rc := range anomalyRootCauses(a) {
+			account := rc.Account
+			if rc.AccountName != "" {
+				account = fmt.Sprintf("%s (%s)", rc.AccountName, rc.Account)
+			}
+			if i == 0 {
+				fmt.Fprintf(w, "%s\t%s\t%.2f\t%.1f\t%s\t", a.Start, a.End, a.Impact, a.ImpactPercent, feedback)
+			} else {
+				fmt.Fprint(w, "\t\t\t\t\t")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", rc.Service, account, rc.Region, rc.UsageType)
+		}
+		total += a.Impact
+	}
+
+	fmt.Fprintln(w, "-----\t---\t------\t--------\t--------\t-------\t-------\t------\t----------")
+	fmt.Fprintf(w, "TOTAL\t\t%.2f\t\t\t\t\t\t\n", total)
+	w.Flush()
+
+	return nil
+}
+
+func init() {
+	anomaliesPeriod.register(awsAnomaliesCmd, 7)
+	awsAnomaliesCmd.Flags().StringVar(&anomaliesMonitorArn, "monitor-arn", "", "only show anomalies from this monitor")
+	awsAnomaliesCmd.Flags().Float64Var(&anomaliesMinImpact, "min-impact", 0, "only show anomalies with at least this total impact")
+	awsAnomaliesCmd.Flags().StringVar(&anomaliesFeedback, "feedback", "", "only show anomalies with this feedback (yes, no, planned-activity)")
+	awsCmd.AddCommand(awsAnomaliesCmd)
+}
@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	commitmentsPeriod      periodFlags
+	commitmentsGranularity string
+)
+
+var awsCommitmentsCmd = &cobra.Command{
+	Use:   "commitments",
+	Short: "Report on Savings Plans and Reserved Instances",
+	Long: `Report how well Savings Plans and Reserved Instances are used and how much
+eligible spend they cover.`,
+}
+
+var awsSPUtilizationCmd = &cobra.Command{
+	Use:   "sp-utilization",
+	Short: "Show Savings Plans utilization",
+	Long: `Show Savings Plans utilization, with the commitment that went unused and
+the net savings for each period.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUtilization(cmd, "savings plans", (*aws.Client).GetSavingsPlansUtilization)
+	},
+}
+
+var awsRIUtilizationCmd = &cobra.Command{
+	Use:   "ri-utilization",
+	Short: "Show Reserved Instance utilization",
+	Long: `Show Reserved Instance utilization, with the amortized cost of unused hours
+and the net savings for each period.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUtilization(cmd, "reserved instance", (*aws.Client).GetReservationUtilization)
+	},
+}
+
+var awsSPCoverageCmd = &cobra.Command{
+	Use:   "sp-coverage",
+	Short: "Show Savings Plans coverage",
+	Long: `Show how much eligible spend Savings Plans covered and the on-demand spend
+left uncovered for each period.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCoverage(cmd, "savings plans", (*aws.Client).GetSavingsPlansCoverage)
+	},
+}
+
+var awsRICoverageCmd = &cobra.Command{
+	Use:   "ri-coverage",
+	Short: "Show Reserved Instance coverage",
+	Long: `Show the share of running hours Reserved Instances covered and the on-demand
+spend left uncovered for each period.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCoverage(cmd, "reserved instance", (*aws.Client).GetReservationCoverage)
+	},
+}
+
+// commitmentQuery builds the query from the shared commitments flags
+func commitmentQuery(cmd *cobra.Command) (aws.CommitmentQuery, error) {
+	window, err := commitmentsPeriod.resolve(cmd)
+	if err != nil {
+		return aws.CommitmentQuery{}, err
+	}
+
+	granularity, err := aws.ParseGranularity(commitmentsGranularity)
+	if err != nil {
+		return aws.CommitmentQuery{}, err
+	}
+	if granularity == types.GranularityHourly {
+		return aws.CommitmentQuery{}, fmt.Errorf("commitment reports support daily or monthly granularity")
+	}
+
+	filter, err := awsFilterExpression()
+	if err != nil {
+		return aws.CommitmentQuery{}, err
+	}
+
+	return aws.CommitmentQuery{Period: window, Granularity: granularity, Filter: filter}, nil
+}
+
+func runUtilization(cmd *cobra.Command, kind string, fetch func(*aws.Client, context.Context, aws.CommitmentQuery) (*aws.UtilizationReport, error)) error {
+	ctx := context.Background()
+
+	q, err := commitmentQuery(cmd)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("fetching aws %s utilization for %s...\n\n", kind, q.Period)
+
+	client, err := newAWSClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create aws client: %w", err)
+	}
+
+	report, err := fetch(client, ctx, q)
+	if err != nil {
+		return fmt.Errorf("failed to get %s utilization: %w", kind, err)
+	}
+
+	switch awsOutput {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case "csv":
+		return utilizationOutputCSV(report)
+	default:
+		return utilizationOutputTable(report)
+	}
+}
+
+func runCoverage(cmd *cobra.Command, kind string, fetch func(*aws.Client, context.Context, aws.CommitmentQuery) (*aws.CoverageReport, error)) error {
+	ctx := context.Background()
+
+	q, err := commitmentQuery(cmd)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("fetching aws %s coverage for %s...\n\n", kind, q.Period)
+
+	client, err := newAWSClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create aws client: %w", err)
+	}
+
+	report, err := fetch(client, ctx, q)
+	if err != nil {
+		return fmt.Errorf("failed to get %s coverage: %w", kind, err)
+	}
+
+	switch awsOutput {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case "csv":
+		return coverageOutputCSV(report)
+	default:
+		return coverageOutputTable(report)
+	}
+}
+
+func utilizationOutputCSV(report *aws.UtilizationReport) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"start", "end", "utilization_percent", "commitment", "used", "unused", "net_savings"})
+
+	for _, u := range report.Periods {
+		w.Write([]string{
+			u.Start, u.End,
+			fmt.Sprintf("%.2f", u.UtilizationPercent),
+			fmt.Sprintf("%.2f", u.Commitment),
+			fmt.Sprintf("%.2f", u.Used),
+			fmt.Sprintf("%.2f", u.Unused),
+			fmt.Sprintf("%.2f", u.NetSavings),
+		})
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func utilizationOutputTable(report *aws.UtilizationReport) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "START\tEND\tUTILIZATION %\tCOMMITMENT\tUSED\tUNUSED\tNET SAVINGS")
+	fmt.Fprintln(w, "-----\t---\t-------------\t----------\t----\t------\t-----------")
+
+	for _, u := range report.Periods {
+		fmt.Fprintf(w, "%s\t%s\t%.1f\t%.2f\t%.2f\t%.2f\t%.2f\n", u.Start, u.End, u.UtilizationPercent, u.Commitment, u.Used, u.Unused, u.NetSavings)
+	}
+
+	t := report.Total
+	fmt.Fprintln(w, "-----\t---\t-------------\t----------\t----\t------\t-----------")
+	fmt.Fprintf(w, "TOTAL\t\t%.1f\t%.2f\t%.2f\t%.2f\t%.2f\n", t.UtilizationPercent, t.Commitment, t.Used, t.Unused, t.NetSavings)
+	w.Flush()
+
+	return nil
+}
+
+func coverageOutputCSV(report *aws.CoverageReport) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"start", "end", "coverage_percent", "covered", "on_demand_cost", "total_cost"})
+
+	for _, c := range report.Periods {
+		w.Write([]string{
+			c.Start, c.End,
+			fmt.Sprintf("%.2f", c.CoveragePercent),
+			fmt.Sprintf("%.2f", c.Covered),
+			fmt.Sprintf("%.2f", c.OnDemandCost),
+			fmt.Sprintf("%.2f", c.TotalCost),
+		})
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func coverageOutputTable(report *aws.CoverageReport) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "START\tEND\tCOVERAGE %\tCOVERED\tON-DEMAND\tTOTAL")
+	fmt.Fprintln(w, "-----\t---\t----------\t-------\t---------\t-----")
+
+	for _, c := range report.Periods {
+		fmt.Fprintf(w, "%s\t%s\t%.1f\t%.2f\t%.2f\t%.2f\n", c.Start, c.End, c.CoveragePercent, c.Covered, c.OnDemandCost, c.TotalCost)
+	}
+
+	t := report.Total
+	fmt.Fprintln(w, "-----\t---\t----------\t-------\t---------\t-----")
+	fmt.Fprintf(w, "TOTAL\t\t%.1f\t%.2f\t%.2f\t%.2f\n", t.CoveragePercent, t.Covered, t.OnDemandCost, t.TotalCost)
+	w.Flush()
+
+	return nil
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{awsSPUtilizationCmd, awsSPCoverageCmd, awsRIUtilizationCmd, awsRICoverageCmd} {
+		commitmentsPeriod.register(cmd, 30)
+		cmd.Flags().StringVarP(&commitmentsGranularity, "granularity", "g", "monthly", "time granularity (daily, monthly)")
+		addFilterFlags(cmd)
+		awsCommitmentsCmd.AddCommand(cmd)
+	}
+	awsCmd.AddCommand(awsCommitmentsCmd)
+}
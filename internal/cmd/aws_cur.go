@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/aws"
+	"github.com/amayabdaniel/dab-cloudcost/internal/aws/cur"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	curPeriod        periodFlags
+	curGranularity   string
+	curByPeriod      bool
+	curGroupBy       []string
+	curLineItemTypes []string
+)
+
+var awsCURCmd = &cobra.Command{
+	Use:   "cur <path>",
+	Short: "Analyze AWS costs from local Cost and Usage Report files",
+	Long: `Analyze AWS costs from Cost and Usage Report (CUR and CUR 2.0) files synced
+to local disk, without calling Cost Explorer. <path> is a directory searched
+for .csv, .csv.gz and .parquet files, a single data file, or a report
+manifest JSON listing the files to read.
+
+Costs are unblended. CUR has line-item detail, so costs can also be grouped
+by resource-id.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAWSCUR,
+}
+
+func runAWSCUR(cmd *cobra.Command, args []string) error {
+	window, err := curPeriod.resolve(cmd)
+	if err != nil {
+		return err
+	}
+
+	granularity, err := aws.ParseGranularity(curGranularity)
+	if err != nil {
+		return err
+	}
+	series := curByPeriod || granularity != types.GranularityMonthly
+
+	accountGroups, err := parseAWSGroupBys(curGroupBy)
+	if err != nil {
+		return err
+	}
+
+	include, err := aws.ParseFilters(awsFilters)
+	if err != nil {
+		return err
+	}
+	exclude, err := aws.ParseFilters(awsExcludes)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("reading cost and usage reports from %s for %s...\n\n", args[0], window)
+
+	costs, err := cur.Report(args[0], cur.Query{
+		Period:        window,
+		Granularity:   granularity,
+		GroupBy:       accountGroups.groupBy,
+		LineItemTypes: curLineItemTypes,
+		Include:       include,
+		Exclude:       exclude,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read cost and usage reports: %w", err)
+	}
+
+	if len(costs) == 0 {
+		fmt.Println("no cost data found")
+		return nil
+	}
+
+	costs, err = accountGroups.applyAccounts(context.Background(), costs)
+	if err != nil {
+		return err
+	}
+
+	return renderCosts(accountGroups.columns(), nil, costs, series)
+}
+
+func init() {
+	curPeriod.register(awsCURCmd, 30)
+	awsCURCmd.Flags().IntVarP(&awsTop, "top", "t", 0, "show top N groups (0 = all)")
+	awsCURCmd.Flags().BoolVar(&curByPeriod, "by-period", false, "break costs down per period instead of only the total")
+	awsCURCmd.Flags().StringVarP(&curGranularity, "granularity", "g", "monthly", "period size (daily, monthly, hourly); daily and hourly imply --by-period")
+	awsCURCmd.Flags().StringSliceVar(&curGroupBy, "group-by", nil, "up to two dimensions to group by: service, linked-account, region, usage-type, operation, resource-id, record-type, tag:<key>, costcategory:<name>, account-tag:<key> (default service)")
+	awsCURCmd.Flags().StringSliceVar(&curLineItemTypes, "line-item-type", nil, "only include these line item types, e.g. Usage,DiscountedUsage,SavingsPlanCoveredUsage (default all)")
+	addFilterFlags(awsCURCmd)
+	awsCmd.AddCommand(awsCURCmd)
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/aws"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dimensionsPeriod    periodFlags
+	discoverySearch     string
+	discoveryLimit      int
+	discoverySortByCost bool
+)
+
+var awsDimensionsCmd = &cobra.Command{
+	Use:   "dimensions <dimension>",
+	Short: "List the values of a cost dimension",
+	Long: `List the values of a Cost Explorer dimension such as service, usage-type,
+region or linked-account that have costs in the selected window, spelled
+exactly as --filter and --group-by expect them.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAWSDimensions,
+}
+
+func runAWSDimensions(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	dimension, err := aws.ParseDimension(args[0])
+	if err != nil {
+		return err
+	}
+
+	q, err := discoveryQuery(cmd, &dimensionsPeriod)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("fetching aws %s values for %s...\n\n", strings.ToLower(string(dimension)), q.Period)
+
+	client, err := newAWSClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create aws client: %w", err)
+	}
+
+	values, err := client.GetDimensionValues(ctx, dimension, q)
+	if err != nil {
+		return fmt.Errorf("failed to get dimension values: %w", err)
+	}
+
+	if len(values) == 0 {
+		fmt.Println("no values found")
+		return nil
+	}
+
+	switch awsOutput {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(values)
+	case "csv":
+		return dimensionsOutputCSV(values)
+	default:
+		return dimensionsOutputTable(values)
+	}
+}
+
+// discoveryQuery builds the query from the period, search and filter flags
+func discoveryQuery(cmd *cobra.Command, p *periodFlags) (aws.DiscoveryQuery, error) {
+	window, err := p.resolve(cmd)
+	if err != nil {
+		return aws.DiscoveryQuery{}, err
+	}
+	filter, err := awsFilterExpression()
+	if err != nil {
+		return aws.DiscoveryQuery{}, err
+	}
+
+	q := aws.DiscoveryQuery{
+		Period:     window,
+		Search:     discoverySearch,
+		Limit:      discoveryLimit,
+		SortByCost: discoverySortByCost,
+		Filter:     filter,
+	}
+	return q, q.Validate()
+}
+
+// attributeString formats dimension attributes as sorted key=value pairs
+func attributeString(attributes map[string]string) string {
+	pairs := make([]string, 0, len(attributes))
+	for k, v := range attributes {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
+
+func dimensionsOutputCSV(values []aws.DimensionValue) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"value", "attributes"})
+
+	for _, v := range values {
+		w.Write([]string{v.Value, attributeString(v.Attributes)})
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func dimensionsOutputTable(values []aws.DimensionValue) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VALUE\tATTRIBUTES")
+	fmt.Fprintln(w, "-----\t----------")
+
+	for _, v := range values {
+		fmt.Fprintf(w, "%s\t%s\n", v.Value, attributeString(v.Attributes))
+	}
+
+	fmt.Fprintln(w, "-----\t----------")
+	fmt.Fprintf(w, "%d values\t\n", len(values))
+	w.Flush()
+
+	return nil
+}
+
+// addDiscoveryFlags registers the search, paging and filter flags shared by
+// the discovery commands
+func addDiscoveryFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&discoverySearch, "search", "", "only list values containing this text")
+	cmd.Flags().IntVar(&discoveryLimit, "limit", 0, "list at most N values (0 = all)")
+	cmd.Flags().BoolVar(&discoverySortByCost, "sort-by-cost", false, "list values with the most unblended cost first; cannot be combined with --search")
+	addFilterFlags(cmd)
+}
+
+func init() {
+	dimensionsPeriod.register(awsDimensionsCmd, 30)
+	addDiscoveryFlags(awsDimensionsCmd)
+	awsCmd.AddCommand(awsDimensionsCmd)
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/aws"
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	forecastHorizon     int
+	forecastGranularity string
+	forecastMetric      string
+	forecastInterval    int32
+)
+
+var awsForecastCmd = &cobra.Command{
+	Use:   "forecast",
+	Short: "Forecast AWS costs",
+	Long: `Forecast AWS costs with Cost Explorer, for the rest of the current month
+or a chosen number of days, with prediction interval bounds.`,
+	RunE: runAWSForecast,
+}
+
+func runAWSForecast(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	window := period.RestOfMonth(time.Now())
+	if forecastHorizon > 0 {
+		window = period.NextDays(forecastHorizon, time.Now())
+	}
+
+	granularity, err := aws.ParseGranularity(forecastGranularity)
+	if err != nil {
+		return err
+	}
+	if granularity == types.GranularityHourly {
+		return fmt.Errorf("forecasts support daily or monthly granularity")
+	}
+
+	metric, err := aws.ParseMetric(forecastMetric)
+	if err != nil {
+		return err
+	}
+
+	filter, err := awsFilterExpression()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("forecasting aws costs for %s...\n\n", window)
+
+	client, err := newAWSClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create aws client: %w", err)
+	}
+
+	forecast, err := client.GetCostForecast(ctx, aws.ForecastQuery{
+		Period:             window,
+		Granularity:        granularity,
+		Metric:             metric,
+		Filter:             filter,
+		PredictionInterval: forecastInterval,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get forecast: %w", err)
+	}
+
+	switch awsOutput {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(forecast)
+	case "csv":
+		return forecastOutputCSV(forecast)
+	default:
+		return forecastOutputTable(forecast)
+	}
+}
+
+// forecastBound formats a total interval bound, which is blank for
+// multi-period forecasts
+func forecastBound(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.2f", *v)
+}
+
+func forecastOutputCSV(f *aws.Forecast) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"start", "end", "mean", "lower", "upper", "unit"})
+
+	for _, p := range f.Periods {
+		w.Write([]string{p.Start, p.End, fmt.Sprintf("%.2f", p.Mean), fmt.Sprintf("%.2f", p.Lower), fmt.Sprintf("%.2f", p.Upper), f.Unit})
+	}
+
+	w.Write([]string{"TOTAL", "", fmt.Sprintf("%.2f", f.Mean), forecastBound(f.Lower), forecastBound(f.Upper), f.Unit})
+	w.Flush()
+	return w.Error()
+}
+
+func forecastOutputTable(f *aws.Forecast) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "START\tEND\tMEAN\tLOWER\tUPPER\tUNIT")
+	fmt.Fprintln(w, "-----\t---\t----\t-----\t-----\t----")
+
+	for _, p := range f.Periods {
+		fmt.Fprintf(w, "%s\t%s\t%.2f\t%.2f\t%.2f\t%s\n", p.Start, p.End, p.Mean, p.Lower, p.Upper, f.Unit)
+	}
+
+	fmt.Fprintln(w, "-----\t---\t----\t-----\t-----\t----")
+	fmt.Fprintf(w, "TOTAL\t\t%.2f\t%s\t%s\t%s\n", f.Mean, forecastBound(f.Lower), forecastBound(f.Upper), f.Unit)
+	w.Flush()
+
+	return nil
+}
+
+func init() {
+	awsForecastCmd.Flags().IntVar(&forecastHorizon, "horizon", 0, "number of days to forecast, starting today (0 = rest of the month)")
+	awsForecastCmd.Flags().StringVarP(&forecastGranularity, "granularity", "g", "monthly", "period size (daily, monthly)")
+	awsForecastCmd.Flags().StringVarP(&forecastMetric, "metric", "m", "unblended", "cost metric to forecast, e.g. unblended, amortized, net-amortized")
+	awsForecastCmd.Flags().Int32Var(&forecastInterval, "interval", aws.DefaultPredictionInterval, "prediction interval level in percent (51-99)")
+	addFilterFlags(awsForecastCmd)
+	awsCmd.AddCommand(awsForecastCmd)
+}
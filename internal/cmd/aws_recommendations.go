@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/aws"
+	"github.com/spf13/cobra"
+)
+
+var (
+	recommendationsTerm     string
+	recommendationsPayment  string
+	recommendationsLookback int
+	recommendationsPlanType string
+	recommendationsService  string
+)
+
+var awsRecommendationsCmd = &cobra.Command{
+	Use:   "recommendations",
+	Short: "Show Savings Plans and Reserved Instance purchase recommendations",
+	Long: `Show commitment purchases Cost Explorer recommends from recent usage, with
+estimated monthly savings, upfront cost and break-even month.`,
+}
+
+var awsSPRecommendationsCmd = &cobra.Command{
+	Use:   "savings-plans",
+	Short: "Show Savings Plans purchase recommendations",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRecommendations("savings plans", (*aws.Client).GetSavingsPlansRecommendations)
+	},
+}
+
+var awsRIRecommendationsCmd = &cobra.Command{
+	Use:   "reservations",
+	Short: "Show Reserved Instance purchase recommendations",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRecommendations("reserved instance", (*aws.Client).GetReservationRecommendations)
+	},
+}
+
+// recommendationQuery builds the query from the recommendations flags
+func recommendationQuery() (aws.RecommendationQuery, error) {
+	term, err := aws.ParseTerm(recommendationsTerm)
+	if err != nil {
+		return aws.RecommendationQuery{}, err
+	}
+	payment, err := aws.ParsePaymentOption(recommendationsPayment)
+	if err != nil {
+		return aws.RecommendationQuery{}, err
+	}
+	lookback, err := aws.ParseLookback(recommendationsLookback)
+	if err != nil {
+		return aws.RecommendationQuery{}, err
+	}
+	planType, err := aws.ParseSavingsPlansType(recommendationsPlanType)
+	if err != nil {
+		return aws.RecommendationQuery{}, err
+	}
+	filter, err := awsFilterExpression()
+	if err != nil {
+		return aws.RecommendationQuery{}, err
+	}
+
+	return aws.RecommendationQuery{
+		Term:             term,
+		Payment:          payment,
+		Lookback:         lookback,
+		SavingsPlansType: planType,
+		Service:          recommendationsService,
+		Filter:           filter,
+	}, nil
+}
+
+func runRecommendations(kind string, fetch func(*aws.Client, context.Context, aws.RecommendationQuery) ([]aws.Recommendation, error)) error {
+	ctx := context.Background()
+
+	q, err := recommendationQuery()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("fetching aws %s recommendations from the last %d days...\n\n", kind, recommendationsLookback)
+
+	client, err := newAWSClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create aws client: %w", err)
+	}
+
+	recs, err := fetch(client, ctx, q)
+	if err != nil {
+		return fmt.Errorf("failed to get %s recommendations: %w", kind, err)
+	}
+
+	if len(recs) == 0 {
+		fmt.Println("no recommendations found")
+		return nil
+	}
+
+	switch awsOutput {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(recs)
+	case "csv":
+		return recommendationsOutputCSV(recs)
+	default:
+		return recommendationsOutputTable(recs)
+	}
+}
+
+func recommendationsOutputCSV(recs []aws.Recommendation) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"account", "resource", "region", "quantity", "hourly_commitment", "upfront_cost", "monthly_savings", "savings_percent", "break_even_months", "unit"})
+
+	for _, r := range recs {
+		w.Write([]string{
+			r.Account, r.Resource, r.Region,
+			fmt.Sprintf("%g", r.Quantity),
+			fmt.Sprintf("%.3f", r.HourlyCommitment),
+			fmt.Sprintf("%.2f", r.UpfrontCost),
+			fmt.Sprintf("%.2f", r.MonthlySavings),
+			fmt.Sprintf("%.2f", r.SavingsPercent),
+			fmt.Sprintf("%.1f", r.BreakEvenMonths),
+			r.Unit,
+		})
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func recommendationsOutputTable(recs []aws.Recommendation) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tRESOURCE\tREGION\tPURCHASE\tUPFRONT\tMONTHLY SAVINGS\tSAVINGS %\tBREAK-EVEN (MONTHS)")
+	fmt.Fprintln(w, "-------\t--------\t------\t--------\t-------\t---------------\t---------\t-------------------")
+
+	var upfront, savings float64
+	for _, r := range recs {
+		purchase := fmt.Sprintf("%g", r.Quantity)
+		if r.HourlyCommitment > 0 {
+			purchase = fmt.Sprintf("%.3f/hr", r.HourlyCommitment)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.2f\t%.2f\t%.1f\t%.1f\n", r.Account, r.Resource, r.Region, purchase, r.UpfrontCost, r.MonthlySavings, r.SavingsPercent, r.BreakEvenMonths)
+		upfront += r.UpfrontCost
+		savings += r.MonthlySavings
+	}
+
+	fmt.Fprintln(w, "-------\t--------\t------\t--------\t-------\t---------------\t---------\t-------------------")
+	fmt.Fprintf(w, "TOTAL\t\t\t\t%.2f\t%.2f\t\t\n", upfront, savings)
+	w.Flush()
+
+	return nil
+}
+
+func init() {
+	flags := awsRecommendationsCmd.PersistentFlags()
+	flags.StringVar(&recommendationsTerm, "term", "1y", "commitment term (1y, 3y)")
+	flags.StringVar(&recommendationsPayment, "payment", "no-upfront", "payment option (no-upfront, partial-upfront, all-upfront)")
+	flags.IntVar(&recommendationsLookback, "lookback", 30, "days of usage to base recommendations on (7, 30, 60)")
+
+	awsSPRecommendationsCmd.Flags().StringVar(&recommendationsPlanType, "plan-type", "compute", "savings plans type (compute, ec2-instance, sagemaker, database)")
+	awsRIRecommendationsCmd.Flags().StringVar(&recommendationsService, "service", aws.DefaultReservationService, "service to recommend reservations for")
+
+	for _, cmd := range []*cobra.Command{awsSPRecommendationsCmd, awsRIRecommendationsCmd} {
+		addFilterFlags(cmd)
+		awsRecommendationsCmd.AddCommand(cmd)
+	}
+	awsCmd.AddCommand(awsRecommendationsCmd)
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/aws"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rightsizingSort        string
+	rightsizingTop         int
+	rightsizingCrossFamily bool
+	rightsizingBenefits    bool
+)
+
+var awsRightsizingCmd = &cobra.Command{
+	Use:   "rightsizing",
+	Short: "Show EC2 rightsizing recommendations",
+	Long: `Show EC2 instances Cost Explorer recommends terminating or resizing, with
+current and target instance types, estimated monthly savings and utilization.`,
+	RunE: runAWSRightsizing,
+}
+
+func runAWSRightsizing(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	if err := aws.CheckRightsizingSort(rightsizingSort); err != nil {
+		return err
+	}
+
+	filter, err := awsFilterExpression()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("fetching aws ec2 rightsizing recommendations...")
+	fmt.Println()
+
+	client, err := newAWSClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create aws client: %w", err)
+	}
+
+	recs, err := client.GetRightsizing(ctx, aws.RightsizingQuery{
+		CrossFamily: rightsizingCrossFamily,
+		Benefits:    rightsizingBenefits,
+		Filter:      filter,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get rightsizing recommendations: %w", err)
+	}
+
+	if err := aws.SortRightsizing(recs, rightsizingSort); err != nil {
+		return err
+	}
+	if rightsizingTop > 0 && rightsizingTop < len(recs) {
+		recs = recs[:rightsizingTop]
+	}
+
+	if len(recs) == 0 {
+		fmt.Println("no recommendations found")
+		return nil
+	}
+
+	switch awsOutput {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(recs)
+	case "csv":
+		return rightsizingOutputCSV(recs)
+	default:
+		return rightsizingOutputTable(recs)
+	}
+}
+
+func rightsizingOutputCSV(recs []aws.Rightsizing) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"account", "instance_id", "instance_name", "action", "region", "current_type", "target_type", "monthly_cost", "monthly_savings", "max_cpu_percent", "max_memory_percent", "unit"})
+
+	for _, r := range recs {
+		w.Write([]string{
+			r.Account, r.InstanceID, r.InstanceName, r.Action, r.Region, r.CurrentType, r.TargetType,
+			fmt.Sprintf("%.2f", r.MonthlyCost),
+			fmt.Sprintf("%.2f", r.MonthlySavings),
+			fmt.Sprintf("%.2f", r.MaxCPU),
+			fmt.Sprintf("%.2f", r.MaxMemory),
+			r.Unit,
+		})
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func rightsizingOutputTable(recs []aws.Rightsizing) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE\tACCOUNT\tREGION\tACTION\tCURRENT\tTARGET\tMAX CPU %\tMONTHLY COST\tMONTHLY SAVINGS")
+	fmt.Fprintln(w, "--------\t-------\t------\t------\t-------\t------\t---------\t------------\t---------------")
+
+	var cost, savings float64
+	for _, r := range recs {
+		instance := r.InstanceID
+		if r.InstanceName != "" {
+			instance = fmt.Sprintf("%s (%s)", r.InstanceName, r.InstanceID)
+		}
+		target := r.TargetType
+		if target == "" {
+			target = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%.1f\t%.2f\t%.2f\n", instance, r.Account, r.Region, r.Action, r.CurrentType, target, r.MaxCPU, r.MonthlyCost, r.MonthlySavings)
+		cost += r.MonthlyCost
+		savings += r.MonthlySavings
+	}
+
+	fmt.Fprintln(w, "--------\t-------\t------\t------\t-------\t------\t---------\t------------\t---------------")
+	fmt.Fprintf(w, "TOTAL\t\t\t\t\t\t\t%.2f\t%.2f\n", cost, savings)
+	w.Flush()
+
+	return nil
+}
+
+func init() {
+	awsRightsizingCmd.Flags().StringVar(&rightsizingSort, "sort", "savings", "sort by ("+strings.Join(aws.RightsizingSortKeys, ", ")+")")
+	awsRightsizingCmd.Flags().IntVarP(&rightsizingTop, "top", "t", 0, "show top N instances (0 = all)")
+	awsRightsizingCmd.Flags().BoolVar(&rightsizingCrossFamily, "cross-family", false, "allow targets outside the current instance family")
+	awsRightsizingCmd.Flags().BoolVar(&rightsizingBenefits, "benefits", true, "account for reserved instance and savings plans discounts")
+	addFilterFlags(awsRightsizingCmd)
+	awsCmd.AddCommand(awsRightsizingCmd)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/aws"
+	"github.com/spf13/cobra"
+)
+
+var tagsPeriod periodFlags
+
+var awsTagsCmd = &cobra.Command{
+	Use:   "tags [key]",
+	Short: "List cost allocation tag keys, or the values of one key",
+	Long: `List the cost allocation tag keys that carry costs in the selected window,
+or the values of one key. Costs without the tag are listed as ` + aws.Untagged + `.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runAWSTags,
+}
+
+func runAWSTags(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	var key string
+	if len(args) == 1 {
+		key = args[0]
+	}
+
+	q, err := discoveryQuery(cmd, &tagsPeriod)
+	if err != nil {
+		return err
+	}
+
+	column := "key"
+	if key == "" {
+		fmt.Printf("fetching aws tag keys for %s...\n\n", q.Period)
+	} else {
+		column = "value"
+		fmt.Printf("fetching aws values of tag %s for %s...\n\n", key, q.Period)
+	}
+
+	client, err := newAWSClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create aws client: %w", err)
+	}
+
+	tags, err := client.GetTags(ctx, key, q)
+	if err != nil {
+		return fmt.Errorf("failed to get tags: %w", err)
+	}
+
+	if len(tags) == 0 {
+		fmt.Println("no tags found")
+		return nil
+	}
+
+	if awsOutput == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(tags)
+	}
+
+	for i, t := range tags {
+		if t == "" {
+			tags[i] = aws.Untagged
+		}
+	}
+	if awsOutput == "csv" {
+		return tagsOutputCSV(column, tags)
+	}
+	return tagsOutputTable(column, tags)
+}
+
+func tagsOutputCSV(column string, tags []string) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{column})
+
+	for _, t := range tags {
+		w.Write([]string{t})
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func tagsOutputTable(column string, tags []string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	rule := strings.Repeat("-", len(column))
+	fmt.Fprintln(w, strings.ToUpper(column))
+	fmt.Fprintln(w, rule)
+
+	for _, t := range tags {
+		fmt.Fprintln(w, t)
+	}
+
+	fmt.Fprintln(w, rule)
+	fmt.Fprintf(w, "%d %ss\n", len(tags), column)
+	w.Flush()
+
+	return nil
+}
+
+func init() {
+	tagsPeriod.register(awsTagsCmd, 30)
+	addDiscoveryFlags(awsTagsCmd)
+	awsCmd.AddCommand(awsTagsCmd)
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/cache"
+	"github.com/spf13/cobra"
+)
+
+var (
+	noCache    bool
+	cacheTTL   time.Duration
+	cachePrune bool
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the local response cache",
+	Long: `Cost Explorer and BigQuery responses are cached on disk. Responses for
+periods that ended before yesterday never expire; others expire after
+--cache-ttl.`,
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove expired cache entries",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := cache.DefaultDir()
+		if err != nil {
+			return fmt.Errorf("failed to find cache directory: %w", err)
+		}
+
+		removed, err := cache.New(dir, cacheTTL).Prune(cachePrune)
+		if err != nil {
+			return fmt.Errorf("failed to prune cache: %w", err)
+		}
+		fmt.Printf("removed %d cache entries from %s\n", removed, dir)
+		return nil
+	},
+}
+
+// openCache returns the response cache, or nil when caching is disabled or
+// the cache directory cannot be found
+func openCache() *cache.Cache {
+	if noCache {
+		return nil
+	}
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: caching disabled: %v\n", err)
+		return nil
+	}
+	return cache.New(dir, cacheTTL)
+}
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "always query the billing apis instead of the local response cache")
+	rootCmd.PersistentFlags().DurationVar(&cacheTTL, "cache-ttl", cache.DefaultTTL, "how long responses for periods that are still open are cached")
+
+	cachePruneCmd.Flags().BoolVar(&cachePrune, "all", false, "remove every entry, not only expired ones")
+	cacheCmd.AddCommand(cachePruneCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
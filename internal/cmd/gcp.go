@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/amayabdaniel/dab-cloudcost/internal/gcp"
@@ -13,11 +14,16 @@ import (
 )
 
 var (
-	gcpDays    int
-	gcpProject string
-	gcpOutput  string
-	gcpTop     int
-	gcpTable   string
+	gcpPeriod   periodFlags
+	gcpProject  string
+	gcpOutput   string
+	gcpTop      int
+	gcpTable    string
+	gcpDates    string
+	gcpFigure   string
+	gcpGroupBy  []string
+	gcpFilters  []string
+	gcpExcludes []string
 )
 
 var gcpCmd = &cobra.Command{
@@ -30,15 +36,52 @@ var gcpCmd = &cobra.Command{
 func runGCP(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 
-	fmt.Printf("fetching gcp costs for project '%s' (last %d days)...\n\n", gcpProject, gcpDays)
+	window, err := gcpPeriod.resolve(cmd)
+	if err != nil {
+		return err
+	}
+
+	dates, err := gcp.ParseDateSemantics(gcpDates)
+	if err != nil {
+		return err
+	}
+
+	figure, err := gcp.ParseCostFigure(gcpFigure)
+	if err != nil {
+		return err
+	}
+
+	groupBy, err := gcp.ParseGroupBys(gcpGroupBy)
+	if err != nil {
+		return err
+	}
+
+	filters, err := gcp.ParseFilters(gcpFilters)
+	if err != nil {
+		return err
+	}
+	excludes, err := gcp.ParseFilters(gcpExcludes)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("fetching gcp costs for project '%s' (%s)...\n\n", gcpProject, window)
 
 	client, err := gcp.NewClient(ctx, gcpProject, gcpTable)
 	if err != nil {
 		return fmt.Errorf("failed to create gcp client: %w", err)
 	}
 	defer client.Close()
-
-	costs, err := client.GetCostsByService(ctx, gcpDays)
+	client = client.WithCache(openCache())
+
+	costs, err := client.GetCosts(ctx, gcp.CostQuery{
+		Period:   window,
+		GroupBy:  groupBy,
+		Dates:    dates,
+		Figure:   figure,
+		Filters:  filters,
+		Excludes: excludes,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to get costs: %w", err)
 	}
@@ -52,30 +95,61 @@ func runGCP(cmd *cobra.Command, args []string) error {
 		costs = costs[:gcpTop]
 	}
 
+	columns := make([]string, len(groupBy))
+	for i, g := range groupBy {
+		columns[i] = g.String()
+	}
+
 	switch gcpOutput {
 	case "json":
-		return gcpOutputJSON(costs)
+		return gcpOutputJSON(columns, costs)
 	case "csv":
-		return gcpOutputCSV(costs)
+		return gcpOutputCSV(columns, costs)
 	default:
-		return gcpOutputTable(costs)
+		return gcpOutputTable(columns, costs)
 	}
 }
 
-func gcpOutputJSON(costs []gcp.CostResult) error {
-	var total float64
+// gcpCreditTypes returns the credit types with credits in costs, in
+// gcp.CreditTypes order
+func gcpCreditTypes(costs []gcp.CostResult) []string {
+	var types []string
+	for _, t := range gcp.CreditTypes {
+		for _, c := range costs {
+			if c.CreditTypes[t] != 0 {
+				types = append(types, t)
+				break
+			}
+		}
+	}
+	return types
+}
+
+func gcpOutputJSON(columns []string, costs []gcp.CostResult) error {
+	var total, gross, credits, net float64
 	for _, c := range costs {
 		total += c.Amount
+		gross += c.Gross
+		credits += c.Credits
+		net += c.Net
 	}
 
 	output := struct {
-		Services []gcp.CostResult `json:"services"`
-		Total    float64          `json:"total"`
-		Unit     string           `json:"unit"`
+		GroupBy []string         `json:"group_by"`
+		Groups  []gcp.CostResult `json:"groups"`
+		Total   float64          `json:"total"`
+		Gross   float64          `json:"gross"`
+		Credits float64          `json:"credits"`
+		Net     float64          `json:"net"`
+		Unit    string           `json:"unit"`
 	}{
-		Services: costs,
-		Total:    total,
-		Unit:     costs[0].Unit,
+		GroupBy: columns,
+		Groups:  costs,
+		Total:   total,
+		Gross:   gross,
+		Credits: credits,
+		Net:     net,
+		Unit:    costs[0].Unit,
 	}
 
 	enc := json.NewEncoder(os.Stdout)
@@ -83,45 +157,90 @@ func gcpOutputJSON(costs []gcp.CostResult) error {
 	return enc.Encode(output)
 }
 
-func gcpOutputCSV(costs []gcp.CostResult) error {
+func gcpOutputCSV(columns []string, costs []gcp.CostResult) error {
 	w := csv.NewWriter(os.Stdout)
-	w.Write([]string{"service", "cost", "unit"})
+	types := gcpCreditTypes(costs)
+	header := append(append([]string{}, columns...), "gross", "credits", "net")
+	for _, t := range types {
+		header = append(header, strings.ToLower(t))
+	}
+	w.Write(append(header, "unit"))
 
-	var total float64
+	var gross, credits, net float64
+	typeTotals := make([]float64, len(types))
 	for _, c := range costs {
-		w.Write([]string{c.Service, fmt.Sprintf("%.2f", c.Amount), c.Unit})
-		total += c.Amount
+		row := append(append([]string{}, c.Keys...), fmt.Sprintf("%.2f", c.Gross), fmt.Sprintf("%.2f", c.Credits), fmt.Sprintf("%.2f", c.Net))
+		for i, t := range types {
+			row = append(row, fmt.Sprintf("%.2f", c.CreditTypes[t]))
+			typeTotals[i] += c.CreditTypes[t]
+		}
+		w.Write(append(row, c.Unit))
+		gross += c.Gross
+		credits += c.Credits
+		net += c.Net
 	}
 
-	w.Write([]string{"TOTAL", fmt.Sprintf("%.2f", total), costs[0].Unit})
+	row := append(totalRow(len(columns)), fmt.Sprintf("%.2f", gross), fmt.Sprintf("%.2f", credits), fmt.Sprintf("%.2f", net))
+	for _, t := range typeTotals {
+		row = append(row, fmt.Sprintf("%.2f", t))
+	}
+	w.Write(append(row, costs[0].Unit))
 	w.Flush()
 	return w.Error()
 }
 
-func gcpOutputTable(costs []gcp.CostResult) error {
+func gcpOutputTable(columns []string, costs []gcp.CostResult) error {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "SERVICE\tCOST\tUNIT")
-	fmt.Fprintln(w, "-------\t----\t----")
 
-	var total float64
+	var header, rule string
+	for _, c := range columns {
+		header += strings.ToUpper(c) + "\t"
+		rule += strings.Repeat("-", len(c)) + "\t"
+	}
+	fmt.Fprintln(w, header+"GROSS\tCREDITS\tNET\tUNIT")
+	fmt.Fprintln(w, rule+"-----\t-------\t---\t----")
+
+	var gross, credits, net float64
 	for _, c := range costs {
-		fmt.Fprintf(w, "%s\t%.2f\t%s\n", c.Service, c.Amount, c.Unit)
-		total += c.Amount
+		fmt.Fprintf(w, "%s\t%.2f\t%.2f\t%.2f\t%s\n", strings.Join(c.Keys, "\t"), c.Gross, c.Credits, c.Net, c.Unit)
+		gross += c.Gross
+		credits += c.Credits
+		net += c.Net
 	}
 
-	fmt.Fprintln(w, "-------\t----\t----")
-	fmt.Fprintf(w, "TOTAL\t%.2f\t%s\n", total, costs[0].Unit)
+	fmt.Fprintln(w, rule+"-----\t-------\t---\t----")
+	fmt.Fprintf(w, "%s\t%.2f\t%.2f\t%.2f\t%s\n", strings.Join(totalRow(len(columns)), "\t"), gross, credits, net, costs[0].Unit)
 	w.Flush()
 
+	if types := gcpCreditTypes(costs); len(types) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CREDIT TYPE\tCREDITS\tUNIT")
+		fmt.Fprintln(w, "-----------\t-------\t----")
+		for _, t := range types {
+			var amount float64
+			for _, c := range costs {
+				amount += c.CreditTypes[t]
+			}
+			fmt.Fprintf(w, "%s\t%.2f\t%s\n", strings.ToLower(t), amount, costs[0].Unit)
+		}
+		w.Flush()
+	}
+
 	return nil
 }
 
 func init() {
-	gcpCmd.Flags().IntVarP(&gcpDays, "days", "d", 30, "number of days to analyze")
+	gcpPeriod.register(gcpCmd, 30)
 	gcpCmd.Flags().StringVarP(&gcpProject, "project", "p", "", "gcp project id (required)")
 	gcpCmd.Flags().StringVarP(&gcpOutput, "output", "o", "table", "output format (table, json, csv)")
-	gcpCmd.Flags().IntVarP(&gcpTop, "top", "t", 0, "show top N services (0 = all)")
+	gcpCmd.Flags().IntVarP(&gcpTop, "top", "t", 0, "show top N groups (0 = all)")
+	gcpCmd.Flags().StringSliceVar(&gcpGroupBy, "group-by", nil, "columns to group by: service, project, project-name, sku, region, cost-type, label:<key>, project-label:<key> (default service)")
+	gcpCmd.Flags().StringArrayVar(&gcpFilters, "filter", nil, "only include costs matching key=value[,value...], e.g. service=Compute Engine or label:env=prod (repeatable)")
+	gcpCmd.Flags().StringArrayVar(&gcpExcludes, "exclude", nil, "exclude costs matching key=value[,value...], e.g. cost-type=tax,adjustment (repeatable)")
 	gcpCmd.Flags().StringVar(&gcpTable, "billing-table", "", "bigquery billing export table (e.g. project.dataset.table)")
+	gcpCmd.Flags().StringVar(&gcpDates, "dates", "usage", "which date places costs in the period: usage (usage_start_time, as in the billing console), invoice (invoice month) or export (export time)")
+	gcpCmd.Flags().StringVar(&gcpFigure, "cost", "net", "cost figure to sort and pick the top services by: net (after credits, as invoiced) or gross")
 	gcpCmd.MarkFlagRequired("project")
 	gcpCmd.MarkFlagRequired("billing-table")
 	rootCmd.AddCommand(gcpCmd)
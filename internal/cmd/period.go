@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"github.com/spf13/cobra"
+)
+
+// periodFlags holds the reporting window flags shared by the aws and gcp
+// commands
+type periodFlags struct {
+	spec period.Spec
+}
+
+// register adds the window flags to cmd, with --days defaulting to days
+func (f *periodFlags) register(cmd *cobra.Command, days int) {
+	cmd.Flags().IntVarP(&f.spec.Days, "days", "d", days, "number of days to analyze, ending yesterday")
+	cmd.Flags().StringVar(&f.spec.Start, "start", "", "first day to analyze (YYYY-MM-DD, UTC)")
+	cmd.Flags().StringVar(&f.spec.End, "end", "", "last day to analyze, inclusive (YYYY-MM-DD, UTC; default yesterday)")
+	cmd.Flags().StringVar(&f.spec.Name, "period", "", "named period ("+strings.Join(period.Names, ", ")+")")
+}
+
+// resolve returns the window selected on cmd. --days cannot be combined with
+// --start or --period.
+func (f *periodFlags) resolve(cmd *cobra.Command) (period.Range, error) {
+	if cmd.Flags().Changed("days") && (f.spec.Start != "" || f.spec.Name != "") {
+		return period.Range{}, fmt.Errorf("--days cannot be combined with --start or --period")
+	}
+	return period.Resolve(f.spec, time.Now())
+}
@@ -7,6 +7,7 @@ import (
 var (
 	version = "dev"
 	cfgFile string
+	verbose bool
 )
 
 var rootCmd = &cobra.Command{
@@ -18,9 +19,13 @@ providing insights and recommendations for cost optimization.`,
 }
 
 func Execute() error {
+	// deferred rather than a post-run hook, which cobra skips when a command
+	// fails, so throttled or failed runs still report what they cost
+	defer reportAWSRequests()
 	return rootCmd.Execute()
 }
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.dab-cloudcost.yaml)")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "print request counts and other diagnostics to stderr")
 }
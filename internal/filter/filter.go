@@ -0,0 +1,32 @@
+// Package filter parses the --filter and --exclude grammar shared by the aws
+// and gcp commands: "<key>=<value>[,<value>...]". What a key means is up to
+// each provider.
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Spec is a parsed filter. Values is empty for "<key>=", which providers use
+// to match costs where a tag or label is absent.
+type Spec struct {
+	Key    string
+	Values []string
+}
+
+// Parse splits s into its key and comma separated values, trimming blanks
+func Parse(s string) (Spec, error) {
+	key, values, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return Spec{}, fmt.Errorf("invalid filter %q (want key=value[,value...])", s)
+	}
+
+	spec := Spec{Key: strings.TrimSpace(key)}
+	for _, v := range strings.Split(values, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			spec.Values = append(spec.Values, v)
+		}
+	}
+	return spec, nil
+}
@@ -0,0 +1,35 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Spec
+		wantErr bool
+	}{
+		{input: "service=Amazon EC2", want: Spec{Key: "service", Values: []string{"Amazon EC2"}}},
+		{input: "record-type=Credit,Refund, Tax", want: Spec{Key: "record-type", Values: []string{"Credit", "Refund", "Tax"}}},
+		{input: "label:env=prod", want: Spec{Key: "label:env", Values: []string{"prod"}}},
+		{input: "url=https://example.com/?a=b", want: Spec{Key: "url", Values: []string{"https://example.com/?a=b"}}},
+		{input: "tag:team=", want: Spec{Key: "tag:team"}},
+		{input: "tag:team= , ", want: Spec{Key: "tag:team"}},
+		{input: "service", wantErr: true},
+		{input: "=prod", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error: got %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package gcp
+
+import (
+	"context"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// Query is a SQL statement and its named parameters
+type Query struct {
+	SQL        string
+	Parameters []bigquery.QueryParameter
+}
+
+// RowIterator yields the rows of a query result. Next returns
+// iterator.Done after the last row.
+type RowIterator interface {
+	Next(dst interface{}) error
+}
+
+// BigQueryAPI runs queries against BigQuery (interface for testing)
+type BigQueryAPI interface {
+	Run(ctx context.Context, q Query) (RowIterator, error)
+	TableMetadata(ctx context.Context, t Table) (*bigquery.TableMetadata, error)
+	Close() error
+}
+
+// bigQueryRunner runs queries with a BigQuery client
+type bigQueryRunner struct {
+	client *bigquery.Client
+}
+
+func (r *bigQueryRunner) Run(ctx context.Context, q Query) (RowIterator, error) {
+	query := r.client.Query(q.SQL)
+	query.Parameters = q.Parameters
+	return query.Read(ctx)
+}
+
+func (r *bigQueryRunner) TableMetadata(ctx context.Context, t Table) (*bigquery.TableMetadata, error) {
+	return r.client.DatasetInProject(t.Project, t.Dataset).Table(t.Name).Metadata(ctx)
+}
+
+func (r *bigQueryRunner) Close() error {
+	return r.client.Close()
+}
@@ -2,73 +2,253 @@ package gcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"sort"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/bigquery"
+	"github.com/amayabdaniel/dab-cloudcost/internal/cache"
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
 )
 
+// CostResult is the cost of one group. Keys holds one value per GroupBy of
+// the query, in the same order. Credits are negative, so Net is Gross plus
+// Credits; Amount is whichever the query selected. CreditTypes breaks Credits
+// down by credit type.
 type CostResult struct {
-	Service string  `json:"service"`
-	Amount  float64 `json:"amount"`
-	Unit    string  `json:"unit"`
+	Keys        []string           `json:"keys"`
+	Amount      float64            `json:"amount"`
+	Unit        string             `json:"unit"`
+	Gross       float64            `json:"gross"`
+	Credits     float64            `json:"credits"`
+	Net         float64            `json:"net"`
+	CreditTypes map[string]float64 `json:"credit_types,omitempty"`
 }
 
-// BigQueryAPI interface for testing
-type BigQueryAPI interface {
-	Query(q string) *bigquery.Query
+// CostQuery describes a cost query. GroupBy defaults to service, Dates to
+// UsageDate and Figure to NetCost. Only costs matching every filter in
+// Filters and none in Excludes are included.
+type CostQuery struct {
+	Period   period.Range
+	GroupBy  []GroupBy
+	Dates    DateSemantics
+	Figure   CostFigure
+	Filters  []Filter
+	Excludes []Filter
 }
 
 type Client struct {
-	bq           *bigquery.Client
+	bq           BigQueryAPI
 	projectID    string
-	billingTable string
+	billingTable Table
+	cache        *cache.Cache
+	table        *tableInfo
 }
 
+// tableInfo is what the client learned about the billing table
+type tableInfo struct {
+	schema               bigquery.Schema
+	ingestionPartitioned bool
+}
+
+// NewClient creates a client querying billingTable, given as
+// project.dataset.table
 func NewClient(ctx context.Context, projectID, billingTable string) (*Client, error) {
+	table, err := ParseTable(billingTable)
+	if err != nil {
+		return nil, err
+	}
+
 	bq, err := bigquery.NewClient(ctx, projectID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create bigquery client: %w", err)
 	}
 
+	return NewClientWithAPI(&bigQueryRunner{client: bq}, projectID, table), nil
+}
+
+// NewClientWithAPI creates a client with a custom API (for testing)
+func NewClientWithAPI(api BigQueryAPI, projectID string, billingTable Table) *Client {
 	return &Client{
-		bq:           bq,
+		bq:           api,
 		projectID:    projectID,
 		billingTable: billingTable,
-	}, nil
+	}
+}
+
+// WithCache returns a copy of c whose query results are cached in store. A
+// nil store disables caching.
+func (c *Client) WithCache(store *cache.Cache) *Client {
+	cached := *c
+	cached.cache = store
+	return &cached
 }
 
 func (c *Client) Close() error {
 	return c.bq.Close()
 }
 
+// GetCostsByService returns costs grouped by service for the last days days
 func (c *Client) GetCostsByService(ctx context.Context, days int) ([]CostResult, error) {
-	query := fmt.Sprintf(`
-		SELECT
-			service.description AS service,
-			SUM(cost) AS amount,
-			currency AS unit
-		FROM %s
-		WHERE DATE(_PARTITIONTIME) >= DATE_SUB(CURRENT_DATE(), INTERVAL %d DAY)
-			AND cost > 0
-		GROUP BY service.description, currency
-		ORDER BY amount DESC
-	`, c.billingTable, days)
-
-	q := c.bq.Query(query)
-	it, err := q.Read(ctx)
+	return c.GetCosts(ctx, CostQuery{Period: period.LastDays(days, time.Now())})
+}
+
+// GetCosts returns costs grouped by q.GroupBy
+func (c *Client) GetCosts(ctx context.Context, q CostQuery) ([]CostResult, error) {
+	table, err := c.checkTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	columns := []string{q.dates().column()}
+	for _, g := range q.groupBy() {
+		columns = append(columns, g.column())
+	}
+	for _, f := range q.Filters {
+		columns = append(columns, f.column())
+	}
+	for _, f := range q.Excludes {
+		columns = append(columns, f.column())
+	}
+	if err := checkSchema(c.billingTable, table.schema, columns...); err != nil {
+		return nil, err
+	}
+
+	query := c.costQuery(q, table)
+
+	key, err := query.cacheKey(c.projectID)
+	if err != nil {
+		return nil, err
+	}
+	if c.cache != nil {
+		var cached []CostResult
+		if ok, _ := c.cache.Get(key, &cached); ok {
+			return SortByAmount(selectFigure(cached, q.Figure)), nil
+		}
+	}
+
+	it, err := c.bq.Run(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to run query: %w", err)
 	}
 
+	results, err := readCostRows(it)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.cache != nil {
+		// a failed write only costs a rerun next time
+		c.cache.Put(key, results, q.dataEnd())
+	}
+	return SortByAmount(selectFigure(results, q.Figure)), nil
+}
+
+func (q CostQuery) groupBy() []GroupBy {
+	if len(q.GroupBy) == 0 {
+		return []GroupBy{ServiceGroupBy}
+	}
+	return q.GroupBy
+}
+
+func (q CostQuery) dates() DateSemantics {
+	if q.Dates == "" {
+		return UsageDate
+	}
+	return q.Dates
+}
+
+// dataEnd returns when the rows q matches are complete, for caching: the end
+// of the last invoice month under InvoiceMonth, which matches whole months,
+// otherwise the end of the period
+func (q CostQuery) dataEnd() time.Time {
+	if q.dates() != InvoiceMonth {
+		return q.Period.End
+	}
+	last := q.Period.End.Add(-time.Nanosecond)
+	return time.Date(last.Year(), last.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// costQuery builds the query for q. Group keys are computed per row in a
+// subquery and aggregated outside it.
+func (c *Client) costQuery(q CostQuery, table *tableInfo) Query {
+	var b queryBuilder
+	groupBy := q.groupBy()
+	keys := make([]string, len(groupBy))
+	aliases := make([]string, len(groupBy))
+	for i, g := range groupBy {
+		aliases[i] = fmt.Sprintf("key_%d", i)
+		keys[i] = b.expression(g, fmt.Sprintf("label_%d", i)) + " AS " + aliases[i]
+	}
+	credits := b.creditColumns(hasColumn(table.schema, "credits.type"))
+	conditions := b.dateFilter(q.dates(), q.Period, table.ingestionPartitioned)
+	conditions = append(conditions, b.filterConditions(q.Filters, q.Excludes)...)
+
+	sql := fmt.Sprintf(`
+		SELECT
+			[%s] AS keys,
+			currency AS unit,
+			SUM(cost) AS gross,
+			%s
+		FROM (
+			SELECT
+				%s,
+				currency,
+				cost,
+				credits
+			FROM %s
+			WHERE %s
+		)
+		GROUP BY %s, currency
+	`, strings.Join(aliases, ", "), credits, strings.Join(keys, ",\n\t\t\t\t"), c.billingTable.Quoted(),
+		strings.Join(conditions, "\n\t\t\t\tAND "), strings.Join(aliases, ", "))
+	return b.query(sql)
+}
+
+// checkTable verifies, once per client, that the billing table exists and
+// has the export schema
+func (c *Client) checkTable(ctx context.Context) (*tableInfo, error) {
+	if c.table != nil {
+		return c.table, nil
+	}
+
+	meta, err := c.bq.TableMetadata(ctx, c.billingTable)
+	if err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			return nil, fmt.Errorf("billing table %s not found", c.billingTable)
+		}
+		return nil, fmt.Errorf("failed to read billing table %s: %w", c.billingTable, err)
+	}
+	if err := checkSchema(c.billingTable, meta.Schema, exportColumns...); err != nil {
+		return nil, err
+	}
+
+	c.table = &tableInfo{
+		schema:               meta.Schema,
+		ingestionPartitioned: meta.TimePartitioning != nil && meta.TimePartitioning.Field == "",
+	}
+	return c.table, nil
+}
+
+// costRow is a row of the cost query
+type costRow struct {
+	Keys        []string  `bigquery:"keys"`
+	Unit        string    `bigquery:"unit"`
+	Gross       float64   `bigquery:"gross"`
+	Credits     float64   `bigquery:"credits"`
+	CreditTypes []float64 `bigquery:"credit_types"`
+}
+
+// readCostRows decodes every row of a cost query
+func readCostRows(it RowIterator) ([]CostResult, error) {
 	var results []CostResult
 	for {
-		var row struct {
-			Service string  `bigquery:"service"`
-			Amount  float64 `bigquery:"amount"`
-			Unit    string  `bigquery:"unit"`
-		}
+		var row costRow
 		err := it.Next(&row)
 		if err == iterator.Done {
 			break
@@ -77,13 +257,15 @@ func (c *Client) GetCostsByService(ctx context.Context, days int) ([]CostResult,
 			return nil, fmt.Errorf("failed to read row: %w", err)
 		}
 		results = append(results, CostResult{
-			Service: row.Service,
-			Amount:  row.Amount,
-			Unit:    row.Unit,
+			Keys:        row.Keys,
+			Unit:        row.Unit,
+			Gross:       row.Gross,
+			Credits:     row.Credits,
+			Net:         row.Gross + row.Credits,
+			CreditTypes: creditTypes(row.CreditTypes),
 		})
 	}
-
-	return SortByAmount(results), nil
+	return results, nil
 }
 
 // SortByAmount sorts results by amount descending
@@ -1,10 +1,104 @@
 package gcp
 
 import (
+	"context"
+	"errors"
 	"math"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/amayabdaniel/dab-cloudcost/internal/cache"
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
 )
 
+// mockBigQuery implements BigQueryAPI for testing. Each query returns rows,
+// which must have the type Next is called with, followed by rowErr or
+// iterator.Done. The table has exportSchema and is partitioned by ingestion
+// time unless meta or metaErr is set.
+type mockBigQuery struct {
+	rows    []any
+	err     error
+	rowErr  error
+	queries []Query
+
+	meta      *bigquery.TableMetadata
+	metaErr   error
+	metaCalls int
+}
+
+// exportSchema is the part of the billing export schema the queries use
+var exportSchema = bigquery.Schema{
+	{Name: "service", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{{Name: "description", Type: bigquery.StringFieldType}}},
+	{Name: "cost", Type: bigquery.FloatFieldType},
+	{Name: "currency", Type: bigquery.StringFieldType},
+	{Name: "cost_type", Type: bigquery.StringFieldType},
+	{Name: "usage_start_time", Type: bigquery.TimestampFieldType},
+	{Name: "export_time", Type: bigquery.TimestampFieldType},
+	{Name: "invoice", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{{Name: "month", Type: bigquery.StringFieldType}}},
+	{Name: "project", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+		{Name: "id", Type: bigquery.StringFieldType},
+		{Name: "name", Type: bigquery.StringFieldType},
+	}},
+	{Name: "labels", Type: bigquery.RecordFieldType, Repeated: true, Schema: bigquery.Schema{
+		{Name: "key", Type: bigquery.StringFieldType},
+		{Name: "value", Type: bigquery.StringFieldType},
+	}},
+	{Name: "credits", Type: bigquery.RecordFieldType, Repeated: true, Schema: bigquery.Schema{
+		{Name: "type", Type: bigquery.StringFieldType},
+		{Name: "amount", Type: bigquery.FloatFieldType},
+	}},
+}
+
+// testTable is the billing table used by client tests
+var testTable = Table{Project: "my-project", Dataset: "billing", Name: "gcp_billing_export"}
+
+func (m *mockBigQuery) Run(ctx context.Context, q Query) (RowIterator, error) {
+	m.queries = append(m.queries, q)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &mockRowIterator{rows: m.rows, err: m.rowErr}, nil
+}
+
+func (m *mockBigQuery) TableMetadata(ctx context.Context, t Table) (*bigquery.TableMetadata, error) {
+	m.metaCalls++
+	if m.metaErr != nil {
+		return nil, m.metaErr
+	}
+	if m.meta != nil {
+		return m.meta, nil
+	}
+	return &bigquery.TableMetadata{Schema: exportSchema, TimePartitioning: &bigquery.TimePartitioning{Type: bigquery.DayPartitioningType}}, nil
+}
+
+func (m *mockBigQuery) Close() error {
+	return nil
+}
+
+type mockRowIterator struct {
+	rows []any
+	err  error
+}
+
+func (it *mockRowIterator) Next(dst interface{}) error {
+	if len(it.rows) == 0 {
+		if it.err != nil {
+			return it.err
+		}
+		return iterator.Done
+	}
+	reflect.ValueOf(dst).Elem().Set(reflect.ValueOf(it.rows[0]))
+	it.rows = it.rows[1:]
+	return nil
+}
+
 func TestSortByAmount(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -19,51 +113,51 @@ func TestSortByAmount(t *testing.T) {
 		{
 			name: "single item",
 			input: []CostResult{
-				{Service: "Compute Engine", Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"Compute Engine"}, Amount: 100.0, Unit: "USD"},
 			},
 			expected: []CostResult{
-				{Service: "Compute Engine", Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"Compute Engine"}, Amount: 100.0, Unit: "USD"},
 			},
 		},
 		{
 			name: "already sorted",
 			input: []CostResult{
-				{Service: "Compute Engine", Amount: 100.0, Unit: "USD"},
-				{Service: "Cloud Storage", Amount: 50.0, Unit: "USD"},
-				{Service: "Cloud Functions", Amount: 10.0, Unit: "USD"},
+				{Keys: []string{"Compute Engine"}, Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"Cloud Storage"}, Amount: 50.0, Unit: "USD"},
+				{Keys: []string{"Cloud Functions"}, Amount: 10.0, Unit: "USD"},
 			},
 			expected: []CostResult{
-				{Service: "Compute Engine", Amount: 100.0, Unit: "USD"},
-				{Service: "Cloud Storage", Amount: 50.0, Unit: "USD"},
-				{Service: "Cloud Functions", Amount: 10.0, Unit: "USD"},
+				{Keys: []string{"Compute Engine"}, Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"Cloud Storage"}, Amount: 50.0, Unit: "USD"},
+				{Keys: []string{"Cloud Functions"}, Amount: 10.0, Unit: "USD"},
 			},
 		},
 		{
 			name: "reverse order",
 			input: []CostResult{
-				{Service: "Cloud Functions", Amount: 10.0, Unit: "USD"},
-				{Service: "Cloud Storage", Amount: 50.0, Unit: "USD"},
-				{Service: "Compute Engine", Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"Cloud Functions"}, Amount: 10.0, Unit: "USD"},
+				{Keys: []string{"Cloud Storage"}, Amount: 50.0, Unit: "USD"},
+				{Keys: []string{"Compute Engine"}, Amount: 100.0, Unit: "USD"},
 			},
 			expected: []CostResult{
-				{Service: "Compute Engine", Amount: 100.0, Unit: "USD"},
-				{Service: "Cloud Storage", Amount: 50.0, Unit: "USD"},
-				{Service: "Cloud Functions", Amount: 10.0, Unit: "USD"},
+				{Keys: []string{"Compute Engine"}, Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"Cloud Storage"}, Amount: 50.0, Unit: "USD"},
+				{Keys: []string{"Cloud Functions"}, Amount: 10.0, Unit: "USD"},
 			},
 		},
 		{
 			name: "mixed order",
 			input: []CostResult{
-				{Service: "Cloud Storage", Amount: 50.0, Unit: "USD"},
-				{Service: "Cloud Functions", Amount: 10.0, Unit: "USD"},
-				{Service: "Compute Engine", Amount: 100.0, Unit: "USD"},
-				{Service: "BigQuery", Amount: 75.0, Unit: "USD"},
+				{Keys: []string{"Cloud Storage"}, Amount: 50.0, Unit: "USD"},
+				{Keys: []string{"Cloud Functions"}, Amount: 10.0, Unit: "USD"},
+				{Keys: []string{"Compute Engine"}, Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"BigQuery"}, Amount: 75.0, Unit: "USD"},
 			},
 			expected: []CostResult{
-				{Service: "Compute Engine", Amount: 100.0, Unit: "USD"},
-				{Service: "BigQuery", Amount: 75.0, Unit: "USD"},
-				{Service: "Cloud Storage", Amount: 50.0, Unit: "USD"},
-				{Service: "Cloud Functions", Amount: 10.0, Unit: "USD"},
+				{Keys: []string{"Compute Engine"}, Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"BigQuery"}, Amount: 75.0, Unit: "USD"},
+				{Keys: []string{"Cloud Storage"}, Amount: 50.0, Unit: "USD"},
+				{Keys: []string{"Cloud Functions"}, Amount: 10.0, Unit: "USD"},
 			},
 		},
 	}
@@ -76,8 +170,8 @@ func TestSortByAmount(t *testing.T) {
 				return
 			}
 			for i := range result {
-				if result[i].Service != tt.expected[i].Service {
-					t.Errorf("index %d: service got %s, want %s", i, result[i].Service, tt.expected[i].Service)
+				if !reflect.DeepEqual(result[i].Keys, tt.expected[i].Keys) {
+					t.Errorf("index %d: keys got %v, want %v", i, result[i].Keys, tt.expected[i].Keys)
 				}
 				if result[i].Amount != tt.expected[i].Amount {
 					t.Errorf("index %d: amount got %f, want %f", i, result[i].Amount, tt.expected[i].Amount)
@@ -101,25 +195,25 @@ func TestTotalCost(t *testing.T) {
 		{
 			name: "single item",
 			input: []CostResult{
-				{Service: "Compute Engine", Amount: 100.50, Unit: "USD"},
+				{Keys: []string{"Compute Engine"}, Amount: 100.50, Unit: "USD"},
 			},
 			expected: 100.50,
 		},
 		{
 			name: "multiple items",
 			input: []CostResult{
-				{Service: "Compute Engine", Amount: 100.0, Unit: "USD"},
-				{Service: "Cloud Storage", Amount: 50.25, Unit: "USD"},
-				{Service: "Cloud Functions", Amount: 10.75, Unit: "USD"},
+				{Keys: []string{"Compute Engine"}, Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"Cloud Storage"}, Amount: 50.25, Unit: "USD"},
+				{Keys: []string{"Cloud Functions"}, Amount: 10.75, Unit: "USD"},
 			},
 			expected: 161.0,
 		},
 		{
 			name: "with zero amounts",
 			input: []CostResult{
-				{Service: "Compute Engine", Amount: 100.0, Unit: "USD"},
-				{Service: "Cloud Storage", Amount: 0.0, Unit: "USD"},
-				{Service: "Cloud Functions", Amount: 50.0, Unit: "USD"},
+				{Keys: []string{"Compute Engine"}, Amount: 100.0, Unit: "USD"},
+				{Keys: []string{"Cloud Storage"}, Amount: 0.0, Unit: "USD"},
+				{Keys: []string{"Cloud Functions"}, Amount: 50.0, Unit: "USD"},
 			},
 			expected: 150.0,
 		},
@@ -134,3 +228,142 @@ func TestTotalCost(t *testing.T) {
 		})
 	}
 }
+
+func TestGetCosts(t *testing.T) {
+	mock := &mockBigQuery{
+		rows: []any{
+			costRow{Keys: []string{"Cloud Storage"}, Unit: "USD", Gross: 12.5},
+			costRow{Keys: []string{"Compute Engine"}, Unit: "USD", Gross: 100, Credits: -30, CreditTypes: []float64{-20, 0, -10}},
+		},
+	}
+	r := period.Range{Start: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)}
+
+	client := NewClientWithAPI(mock, "my-project", testTable)
+	results, err := client.GetCosts(context.Background(), CostQuery{Period: r})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []CostResult{
+		{
+			Keys: []string{"Compute Engine"}, Amount: 70, Unit: "USD", Gross: 100, Credits: -30, Net: 70,
+			CreditTypes: map[string]float64{"COMMITTED_USAGE_DISCOUNT": -20, "SUSTAINED_USAGE_DISCOUNT": -10},
+		},
+		{Keys: []string{"Cloud Storage"}, Amount: 12.5, Unit: "USD", Gross: 12.5, Net: 12.5},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("results: got %+v, want %+v", results, want)
+	}
+
+	q := mock.queries[0]
+	for _, s := range []string{"FROM `my-project.billing.gcp_billing_export`", "usage_start_time >= @start_time", "usage_start_time < @end_time", "DATE(_PARTITIONTIME) >= @partition_start"} {
+		if !strings.Contains(q.SQL, s) {
+			t.Errorf("query missing %q:\n%s", s, q.SQL)
+		}
+	}
+	params := make(map[string]any)
+	for _, p := range q.Parameters {
+		params[p.Name] = p.Value
+	}
+	wantParams := map[string]any{
+		"start_time":      r.Start,
+		"end_time":        r.End,
+		"partition_start": civil.Date{Year: 2024, Month: 2, Day: 29},
+		"credit_type_0":   "COMMITTED_USAGE_DISCOUNT",
+	}
+	for name, want := range wantParams {
+		if !reflect.DeepEqual(params[name], want) {
+			t.Errorf("parameter %s: got %v, want %v", name, params[name], want)
+		}
+	}
+}
+
+func TestGetCostsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		mock *mockBigQuery
+		want string
+	}{
+		{name: "query", mock: &mockBigQuery{err: errors.New("access denied")}, want: "failed to run query"},
+		{name: "row", mock: &mockBigQuery{rows: []any{costRow{Keys: []string{"Compute Engine"}}}, rowErr: errors.New("bad row")}, want: "failed to read row"},
+		{name: "missing table", mock: &mockBigQuery{metaErr: &googleapi.Error{Code: http.StatusNotFound}}, want: "billing table my-project.billing.gcp_billing_export not found"},
+		{name: "not an export", mock: &mockBigQuery{meta: &bigquery.TableMetadata{Schema: bigquery.Schema{{Name: "cost"}}}}, want: "missing service.description, currency, usage_start_time, credits.amount"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClientWithAPI(tt.mock, "my-project", testTable)
+			_, err := client.GetCosts(context.Background(), CostQuery{Period: period.LastDays(30, time.Now())})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error: got %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCostsCached(t *testing.T) {
+	mock := &mockBigQuery{rows: []any{costRow{Keys: []string{"Compute Engine"}, Unit: "USD", Gross: 100, Credits: -25}}}
+	client := NewClientWithAPI(mock, "my-project", testTable).WithCache(cache.New(t.TempDir(), time.Hour))
+	r := period.LastDays(30, time.Now())
+
+	// the cached rows serve either figure
+	for i, figure := range []CostFigure{NetCost, GrossCost} {
+		results, err := client.GetCosts(context.Background(), CostQuery{Period: r, Figure: figure})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []float64{75, 100}[i]
+		if len(results) != 1 || results[0].Amount != want {
+			t.Errorf("%s: got %+v, want amount %v", figure, results, want)
+		}
+	}
+	if len(mock.queries) != 1 {
+		t.Errorf("queries: got %d, want 1 (cached)", len(mock.queries))
+	}
+	if mock.metaCalls != 1 {
+		t.Errorf("table checks: got %d, want 1", mock.metaCalls)
+	}
+}
+
+func TestCostQueryDataEnd(t *testing.T) {
+	r := period.Range{Start: time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2026, 9, 10, 0, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		dates DateSemantics
+		r     period.Range
+		want  time.Time
+	}{
+		{dates: UsageDate, r: r, want: r.End},
+		{dates: InvoiceMonth, r: r, want: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{dates: InvoiceMonth, r: period.Range{Start: r.Start, End: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)}, want: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{dates: InvoiceMonth, r: period.Range{Start: r.Start, End: time.Date(2027, 1, 2, 0, 0, 0, 0, time.UTC)}, want: time.Date(2027, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.dates)+" "+tt.r.End.Format(period.DateLayout), func(t *testing.T) {
+			if got := (CostQuery{Period: tt.r, Dates: tt.dates}).dataEnd(); !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCostsInvoiceMonthNotCachedForever(t *testing.T) {
+	mock := &mockBigQuery{rows: []any{costRow{Keys: []string{"Compute Engine"}, Unit: "USD", Gross: 100}}}
+	// entries that can expire are expired at once, so only final ones hit
+	client := NewClientWithAPI(mock, "my-project", testTable).WithCache(cache.New(t.TempDir(), -time.Hour))
+
+	// the first day of the current invoice month, which is final from the
+	// 3rd while the month keeps accumulating rows
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	q := CostQuery{Period: period.Range{Start: start, End: start.AddDate(0, 0, 1)}, Dates: InvoiceMonth}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetCosts(context.Background(), q); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(mock.queries) != 2 {
+		t.Errorf("queries: got %d, want 2 (open invoice month not cached)", len(mock.queries))
+	}
+}
@@ -0,0 +1,85 @@
+package gcp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CostFigure selects which cost figure results are sorted and totalled by
+type CostFigure string
+
+const (
+	// NetCost is the cost after credits, as invoiced
+	NetCost CostFigure = "net"
+	// GrossCost is the cost before credits
+	GrossCost CostFigure = "gross"
+)
+
+// ParseCostFigure converts net or gross into a CostFigure, defaulting to
+// NetCost
+func ParseCostFigure(s string) (CostFigure, error) {
+	switch f := CostFigure(strings.ToLower(s)); f {
+	case "":
+		return NetCost, nil
+	case NetCost, GrossCost:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown cost figure %q (want net or gross)", s)
+}
+
+// CreditTypes are the billing export credit types broken down per result.
+// Credits of other types only count towards the credit total.
+var CreditTypes = []string{
+	"COMMITTED_USAGE_DISCOUNT",
+	"COMMITTED_USAGE_DISCOUNT_DOLLAR_BASE",
+	"SUSTAINED_USAGE_DISCOUNT",
+	"FREE_TIER",
+	"PROMOTION",
+	"DISCOUNT",
+	"SUBSCRIPTION_BENEFIT",
+	"RESELLER_MARGIN",
+	"FEE_UTILIZATION_OFFSET",
+}
+
+// creditColumns returns the SELECT expressions summing all credits and, when
+// the export has credit types, each of CreditTypes in the same order
+func (b *queryBuilder) creditColumns(byType bool) string {
+	columns := "SUM(IFNULL((SELECT SUM(c.amount) FROM UNNEST(credits) c), 0)) AS credits"
+	if !byType {
+		return columns + ",\n\t\t\tCAST([] AS ARRAY<FLOAT64>) AS credit_types"
+	}
+
+	sums := make([]string, len(CreditTypes))
+	for i, t := range CreditTypes {
+		sums[i] = fmt.Sprintf("SUM(IFNULL((SELECT SUM(c.amount) FROM UNNEST(credits) c WHERE c.type = %s), 0))", b.param(fmt.Sprintf("credit_type_%d", i), t))
+	}
+	return columns + ",\n\t\t\t[" + strings.Join(sums, ",\n\t\t\t\t") + "] AS credit_types"
+}
+
+// creditTypes maps the non-zero per-type sums of a row to their types
+func creditTypes(sums []float64) map[string]float64 {
+	var types map[string]float64
+	for i, amount := range sums {
+		if i >= len(CreditTypes) || amount == 0 {
+			continue
+		}
+		if types == nil {
+			types = make(map[string]float64)
+		}
+		types[CreditTypes[i]] = amount
+	}
+	return types
+}
+
+// selectFigure sets Amount of each result to figure
+func selectFigure(results []CostResult, figure CostFigure) []CostResult {
+	selected := make([]CostResult, len(results))
+	for i, r := range results {
+		r.Amount = r.Net
+		if figure == GrossCost {
+			r.Amount = r.Gross
+		}
+		selected[i] = r
+	}
+	return selected
+}
@@ -0,0 +1,59 @@
+package gcp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCostFigure(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    CostFigure
+		wantErr bool
+	}{
+		{input: "", want: NetCost},
+		{input: "net", want: NetCost},
+		{input: "Gross", want: GrossCost},
+		{input: "list", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseCostFigure(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error: got %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreditColumns(t *testing.T) {
+	var byType queryBuilder
+	columns := byType.creditColumns(true)
+	if len(byType.params) != len(CreditTypes) || !strings.Contains(columns, "c.type = @credit_type_0") {
+		t.Errorf("by type: got %d params, columns:\n%s", len(byType.params), columns)
+	}
+
+	// exports without credit types still get an empty breakdown
+	var total queryBuilder
+	columns = total.creditColumns(false)
+	if len(total.params) != 0 || !strings.Contains(columns, "AS credit_types") || strings.Contains(columns, "c.type") {
+		t.Errorf("without types: got %d params, columns:\n%s", len(total.params), columns)
+	}
+}
+
+func TestCreditTypes(t *testing.T) {
+	if got := creditTypes([]float64{0, 0, 0}); got != nil {
+		t.Errorf("no credits: got %v, want nil", got)
+	}
+
+	got := creditTypes([]float64{-5, 0, -2.5})
+	want := map[string]float64{"COMMITTED_USAGE_DISCOUNT": -5, "SUSTAINED_USAGE_DISCOUNT": -2.5}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
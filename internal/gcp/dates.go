@@ -0,0 +1,92 @@
+package gcp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+)
+
+// DateSemantics selects which date of a billing row places it in a period
+type DateSemantics string
+
+const (
+	// UsageDate matches rows by usage_start_time, like the Cloud Billing
+	// console reports
+	UsageDate DateSemantics = "usage"
+	// InvoiceMonth matches rows by the invoice month they were billed in
+	InvoiceMonth DateSemantics = "invoice"
+	// ExportTime matches rows by when they were exported to BigQuery
+	ExportTime DateSemantics = "export"
+)
+
+// ParseDateSemantics converts usage, invoice or export into DateSemantics,
+// defaulting to UsageDate
+func ParseDateSemantics(s string) (DateSemantics, error) {
+	switch d := DateSemantics(strings.ToLower(s)); d {
+	case "":
+		return UsageDate, nil
+	case UsageDate, InvoiceMonth, ExportTime:
+		return d, nil
+	}
+	return "", fmt.Errorf("unknown date semantics %q (want usage, invoice or export)", s)
+}
+
+// column returns the export column d filters on
+func (d DateSemantics) column() string {
+	switch d {
+	case InvoiceMonth:
+		return "invoice.month"
+	case ExportTime:
+		return "export_time"
+	}
+	return "usage_start_time"
+}
+
+// dateFilter returns the WHERE conditions selecting rows in r by d. Tables
+// partitioned by ingestion time also get a _PARTITIONTIME bound so BigQuery
+// only scans partitions that can hold matching rows; rows are exported at or
+// after usage, but late rows can arrive any time later.
+func (b *queryBuilder) dateFilter(d DateSemantics, r period.Range, ingestionPartitioned bool) []string {
+	var conditions []string
+	switch d {
+	case InvoiceMonth:
+		conditions = append(conditions, "invoice.month IN UNNEST("+b.param("invoice_months", invoiceMonths(r))+")")
+	default:
+		column := d.column()
+		conditions = append(conditions,
+			column+" >= "+b.param("start_time", r.Start),
+			column+" < "+b.param("end_time", r.End),
+		)
+	}
+
+	if !ingestionPartitioned {
+		return conditions
+	}
+
+	// a day of slack covers rows exported around midnight and invoice months,
+	// which start in Pacific time. Invoice months are matched whole, so they
+	// are bounded from the first day of the first month, not from r.Start.
+	first := r.Start
+	if d == InvoiceMonth {
+		first = time.Date(r.Start.Year(), r.Start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	start := civil.DateOf(first).AddDays(-1)
+	conditions = append(conditions, "DATE(_PARTITIONTIME) >= "+b.param("partition_start", start))
+	if d == ExportTime {
+		conditions = append(conditions, "DATE(_PARTITIONTIME) <= "+b.param("partition_end", civil.DateOf(r.End).AddDays(1)))
+	}
+	return conditions
+}
+
+// invoiceMonths returns the YYYYMM invoice months overlapping r
+func invoiceMonths(r period.Range) []string {
+	var months []string
+	last := r.End.Add(-time.Nanosecond)
+	for m := time.Date(r.Start.Year(), r.Start.Month(), 1, 0, 0, 0, 0, time.UTC); !m.After(last); m = m.AddDate(0, 1, 0) {
+		months = append(months, m.Format("200601"))
+	}
+	return months
+}
@@ -0,0 +1,138 @@
+package gcp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+)
+
+func TestParseDateSemantics(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    DateSemantics
+		wantErr bool
+	}{
+		{input: "", want: UsageDate},
+		{input: "usage", want: UsageDate},
+		{input: "Invoice", want: InvoiceMonth},
+		{input: "export", want: ExportTime},
+		{input: "partition", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDateSemantics(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error: got %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDateFilter(t *testing.T) {
+	r := period.Range{Start: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name        string
+		dates       DateSemantics
+		r           period.Range
+		partitioned bool
+		want        []string
+		wantParams  map[string]any
+	}{
+		{
+			name:        "usage",
+			dates:       UsageDate,
+			partitioned: true,
+			want:        []string{"usage_start_time >= @start_time", "usage_start_time < @end_time", "DATE(_PARTITIONTIME) >= @partition_start"},
+			wantParams:  map[string]any{"start_time": r.Start, "end_time": r.End, "partition_start": civil.Date{Year: 2024, Month: 2, Day: 29}},
+		},
+		{
+			name:       "usage without ingestion partitioning",
+			dates:      UsageDate,
+			want:       []string{"usage_start_time >= @start_time", "usage_start_time < @end_time"},
+			wantParams: map[string]any{"start_time": r.Start, "end_time": r.End},
+		},
+		{
+			name:        "invoice",
+			dates:       InvoiceMonth,
+			partitioned: true,
+			want:        []string{"invoice.month IN UNNEST(@invoice_months)", "DATE(_PARTITIONTIME) >= @partition_start"},
+			wantParams:  map[string]any{"invoice_months": []string{"202403"}, "partition_start": civil.Date{Year: 2024, Month: 2, Day: 29}},
+		},
+		{
+			name:        "invoice from mid-month",
+			dates:       InvoiceMonth,
+			r:           period.Range{Start: time.Date(2023, 12, 15, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC)},
+			partitioned: true,
+			want:        []string{"invoice.month IN UNNEST(@invoice_months)", "DATE(_PARTITIONTIME) >= @partition_start"},
+			wantParams:  map[string]any{"invoice_months": []string{"202312", "202401", "202402"}, "partition_start": civil.Date{Year: 2023, Month: 11, Day: 30}},
+		},
+		{
+			name:        "export",
+			dates:       ExportTime,
+			partitioned: true,
+			want:        []string{"export_time >= @start_time", "export_time < @end_time", "DATE(_PARTITIONTIME) >= @partition_start", "DATE(_PARTITIONTIME) <= @partition_end"},
+			wantParams: map[string]any{
+				"start_time": r.Start, "end_time": r.End,
+				"partition_start": civil.Date{Year: 2024, Month: 2, Day: 29}, "partition_end": civil.Date{Year: 2024, Month: 4, Day: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := r
+			if !tt.r.Start.IsZero() {
+				rng = tt.r
+			}
+			var b queryBuilder
+			got := b.dateFilter(tt.dates, rng, tt.partitioned)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("conditions:\n got %s\nwant %s", strings.Join(got, " AND "), strings.Join(tt.want, " AND "))
+			}
+
+			params := make(map[string]any)
+			for _, p := range b.params {
+				params[p.Name] = p.Value
+			}
+			if !reflect.DeepEqual(params, tt.wantParams) {
+				t.Errorf("parameters: got %v, want %v", params, tt.wantParams)
+			}
+		})
+	}
+}
+
+func TestInvoiceMonths(t *testing.T) {
+	tests := []struct {
+		name string
+		r    period.Range
+		want []string
+	}{
+		{
+			name: "one month",
+			r:    period.Range{Start: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+			want: []string{"202403"},
+		},
+		{
+			name: "across a year",
+			r:    period.Range{Start: time.Date(2023, 12, 15, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC)},
+			want: []string{"202312", "202401", "202402"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := invoiceMonths(tt.r); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package gcp
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/filter"
+)
+
+// CostTypes are the values of the export cost_type column
+var CostTypes = []string{"regular", "tax", "adjustment", "rounding_error"}
+
+// Filter matches costs whose group-by key has one of Values. A label filter
+// without values matches costs where the label is absent.
+type Filter struct {
+	GroupBy
+	Values []string
+}
+
+// ParseFilter parses a filter of the form "<key>=<value>[,<value>...]", where
+// key is anything accepted by ParseGroupBy, e.g. "service=Compute Engine",
+// "cost-type=tax,adjustment" or "label:env=prod"
+func ParseFilter(s string) (Filter, error) {
+	spec, err := filter.Parse(s)
+	if err != nil {
+		return Filter{}, err
+	}
+
+	g, err := ParseGroupBy(spec.Key)
+	if err != nil {
+		return Filter{}, fmt.Errorf("invalid filter %q: %w", s, err)
+	}
+
+	f := Filter{GroupBy: g, Values: spec.Values}
+	if len(f.Values) == 0 && g.Type == ColumnGroupBy {
+		return Filter{}, fmt.Errorf("invalid filter %q: no values", s)
+	}
+	if g.Key == "cost_type" {
+		for i, v := range f.Values {
+			f.Values[i] = strings.ToLower(v)
+			if !slices.Contains(CostTypes, f.Values[i]) {
+				return Filter{}, fmt.Errorf("invalid filter %q: unknown cost type %q (want %s)", s, v, strings.Join(CostTypes, ", "))
+			}
+		}
+	}
+	return f, nil
+}
+
+// ParseFilters parses each value with ParseFilter
+func ParseFilters(values []string) ([]Filter, error) {
+	filters := make([]Filter, len(values))
+	for i, v := range values {
+		f, err := ParseFilter(v)
+		if err != nil {
+			return nil, err
+		}
+		filters[i] = f
+	}
+	return filters, nil
+}
+
+// condition returns the WHERE condition matching f. It compares the same
+// expression costs are grouped by, so the (none) and (untagged) keys shown in
+// reports can be filtered on, and excluding never drops rows with NULLs.
+func (b *queryBuilder) condition(f Filter, index int) string {
+	key := b.expression(f.GroupBy, fmt.Sprintf("filter_label_%d", index))
+	if len(f.Values) == 0 {
+		return key + " = " + b.param("untagged", Untagged)
+	}
+	return fmt.Sprintf("%s IN UNNEST(%s)", key, b.param(fmt.Sprintf("filter_%d", index), f.Values))
+}
+
+// filterConditions returns the conditions for include and the negated
+// conditions for exclude
+func (b *queryBuilder) filterConditions(include, exclude []Filter) []string {
+	var conditions []string
+	for _, f := range include {
+		conditions = append(conditions, b.condition(f, len(conditions)))
+	}
+	for _, f := range exclude {
+		conditions = append(conditions, "NOT ("+b.condition(f, len(conditions))+")")
+	}
+	return conditions
+}
@@ -0,0 +1,104 @@
+package gcp
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Filter
+		wantErr bool
+	}{
+		{input: "service=Compute Engine", want: Filter{GroupBy: ServiceGroupBy, Values: []string{"Compute Engine"}}},
+		{input: "project=a, b", want: Filter{GroupBy: GroupBy{Type: ColumnGroupBy, Key: "project.id"}, Values: []string{"a", "b"}}},
+		{input: "cost-type=Tax,adjustment", want: Filter{GroupBy: GroupBy{Type: ColumnGroupBy, Key: "cost_type"}, Values: []string{"tax", "adjustment"}}},
+		{input: "label:env=prod", want: Filter{GroupBy: GroupBy{Type: LabelGroupBy, Key: "env"}, Values: []string{"prod"}}},
+		{input: "label:team=", want: Filter{GroupBy: GroupBy{Type: LabelGroupBy, Key: "team"}}},
+		{input: "cost-type=credit", wantErr: true},
+		{input: "region=", wantErr: true},
+		{input: "tag:env=prod", wantErr: true},
+		{input: "service", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseFilter(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error: got %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterConditions(t *testing.T) {
+	include := []Filter{
+		{GroupBy: ServiceGroupBy, Values: []string{"Compute Engine"}},
+		{GroupBy: GroupBy{Type: LabelGroupBy, Key: "team"}},
+	}
+	exclude := []Filter{
+		{GroupBy: GroupBy{Type: ColumnGroupBy, Key: "cost_type"}, Values: []string{"tax"}},
+	}
+
+	var b queryBuilder
+	got := b.filterConditions(include, exclude)
+	want := []string{
+		"IFNULL(service.description, @no_value) IN UNNEST(@filter_0)",
+		"IFNULL((SELECT l.value FROM UNNEST(labels) l WHERE l.key = @filter_label_1 LIMIT 1), @untagged) = @untagged",
+		"NOT (IFNULL(cost_type, @no_value) IN UNNEST(@filter_2))",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("conditions:\n got %s\nwant %s", strings.Join(got, "\n     "), strings.Join(want, "\n     "))
+	}
+
+	params := make(map[string]any)
+	for _, p := range b.params {
+		params[p.Name] = p.Value
+	}
+	wantParams := map[string]any{
+		"no_value":       NoValue,
+		"untagged":       Untagged,
+		"filter_0":       []string{"Compute Engine"},
+		"filter_label_1": "team",
+		"filter_2":       []string{"tax"},
+	}
+	if !reflect.DeepEqual(params, wantParams) {
+		t.Errorf("parameters: got %v, want %v", params, wantParams)
+	}
+}
+
+func TestGetCostsFiltered(t *testing.T) {
+	mock := &mockBigQuery{}
+	client := NewClientWithAPI(mock, "my-project", testTable)
+
+	_, err := client.GetCosts(context.Background(), CostQuery{
+		Period:   period.LastDays(30, time.Now()),
+		Filters:  []Filter{{GroupBy: GroupBy{Type: LabelGroupBy, Key: "env"}, Values: []string{"prod"}}},
+		Excludes: []Filter{{GroupBy: GroupBy{Type: ColumnGroupBy, Key: "cost_type"}, Values: []string{"tax"}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sql := mock.queries[0].SQL
+	for _, s := range []string{
+		"AND IFNULL((SELECT l.value FROM UNNEST(labels) l WHERE l.key = @filter_label_0 LIMIT 1), @untagged) IN UNNEST(@filter_0)",
+		"AND NOT (IFNULL(cost_type, @no_value) IN UNNEST(@filter_1))",
+	} {
+		if !strings.Contains(sql, s) {
+			t.Errorf("query missing %q:\n%s", s, sql)
+		}
+	}
+	if strings.Contains(sql, "prod") || strings.Contains(sql, "'tax'") {
+		t.Errorf("filter values must be parameters:\n%s", sql)
+	}
+}
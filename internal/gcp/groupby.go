@@ -0,0 +1,133 @@
+package gcp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GroupByType is the kind of a GroupBy
+type GroupByType string
+
+const (
+	// ColumnGroupBy groups by an export column such as service.description
+	ColumnGroupBy GroupByType = "column"
+	// LabelGroupBy groups by the value of a resource label
+	LabelGroupBy GroupByType = "label"
+	// ProjectLabelGroupBy groups by the value of a project label
+	ProjectLabelGroupBy GroupByType = "project-label"
+)
+
+// GroupBy is a grouping of billing rows: an export column, or the key of a
+// resource or project label
+type GroupBy struct {
+	Type GroupByType
+	Key  string
+}
+
+// Untagged is the key shown for costs without a value for a label group-by
+const Untagged = "(untagged)"
+
+// NoValue is the key shown for costs without a value for a column group-by,
+// such as charges that belong to no project
+const NoValue = "(none)"
+
+// ServiceGroupBy groups costs by service
+var ServiceGroupBy = GroupBy{Type: ColumnGroupBy, Key: "service.description"}
+
+// groupByColumns maps group-by names to export columns
+var groupByColumns = map[string]string{
+	"service":      "service.description",
+	"project":      "project.id",
+	"project-id":   "project.id",
+	"project-name": "project.name",
+	"sku":          "sku.description",
+	"region":       "location.region",
+	"cost-type":    "cost_type",
+}
+
+// ParseGroupBy parses a group-by such as "service", "project-name",
+// "sku.description", "label:team" or "project-label:cost-center"
+func ParseGroupBy(s string) (GroupBy, error) {
+	if prefix, key, ok := strings.Cut(s, ":"); ok {
+		if key == "" {
+			return GroupBy{}, fmt.Errorf("missing key in group-by %q", s)
+		}
+		switch strings.ToLower(prefix) {
+		case "label":
+			return GroupBy{Type: LabelGroupBy, Key: key}, nil
+		case "project-label":
+			return GroupBy{Type: ProjectLabelGroupBy, Key: key}, nil
+		}
+		return GroupBy{}, fmt.Errorf("unknown group-by type %q", prefix)
+	}
+
+	name := strings.ToLower(strings.TrimSpace(s))
+	if column, ok := groupByColumns[name]; ok {
+		return GroupBy{Type: ColumnGroupBy, Key: column}, nil
+	}
+	for _, column := range groupByColumns {
+		if name == column {
+			return GroupBy{Type: ColumnGroupBy, Key: column}, nil
+		}
+	}
+	return GroupBy{}, fmt.Errorf("unknown group-by %q", s)
+}
+
+// ParseGroupBys parses groupings, defaulting to service when none are given
+func ParseGroupBys(values []string) ([]GroupBy, error) {
+	if len(values) == 0 {
+		return []GroupBy{ServiceGroupBy}, nil
+	}
+	groupBy := make([]GroupBy, len(values))
+	for i, v := range values {
+		g, err := ParseGroupBy(v)
+		if err != nil {
+			return nil, err
+		}
+		groupBy[i] = g
+	}
+	return groupBy, nil
+}
+
+// String returns the group-by in the form accepted by ParseGroupBy
+func (g GroupBy) String() string {
+	switch g.Type {
+	case LabelGroupBy, ProjectLabelGroupBy:
+		return string(g.Type) + ":" + g.Key
+	}
+	for name, column := range groupByColumns {
+		// project.id has two names; prefer the shorter one
+		if column == g.Key && name != "project-id" {
+			return name
+		}
+	}
+	return g.Key
+}
+
+// column returns the export column g reads, for schema checks
+func (g GroupBy) column() string {
+	switch g.Type {
+	case LabelGroupBy:
+		return "labels.key"
+	case ProjectLabelGroupBy:
+		return "project.labels.key"
+	}
+	return g.Key
+}
+
+// expression returns the SQL expression for the key of g, passing a label key
+// as parameter labelParam. Labels are looked up with a subquery rather than
+// joined, so rows are not counted once per label.
+func (b *queryBuilder) expression(g GroupBy, labelParam string) string {
+	switch g.Type {
+	case LabelGroupBy, ProjectLabelGroupBy:
+		labels := "labels"
+		if g.Type == ProjectLabelGroupBy {
+			labels = "project.labels"
+		}
+		key := b.param(labelParam, g.Key)
+		return fmt.Sprintf("IFNULL((SELECT l.value FROM UNNEST(%s) l WHERE l.key = %s LIMIT 1), %s)", labels, key, b.param("untagged", Untagged))
+	}
+	// the column comes from groupByColumns, never from user input
+	return fmt.Sprintf("IFNULL(%s, %s)", g.Key, b.param("no_value", NoValue))
+}
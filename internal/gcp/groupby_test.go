@@ -0,0 +1,128 @@
+package gcp
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/amayabdaniel/dab-cloudcost/internal/period"
+)
+
+func TestParseGroupBy(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    GroupBy
+		wantErr bool
+	}{
+		{input: "service", want: ServiceGroupBy},
+		{input: "project", want: GroupBy{Type: ColumnGroupBy, Key: "project.id"}},
+		{input: "project.name", want: GroupBy{Type: ColumnGroupBy, Key: "project.name"}},
+		{input: "SKU", want: GroupBy{Type: ColumnGroupBy, Key: "sku.description"}},
+		{input: "region", want: GroupBy{Type: ColumnGroupBy, Key: "location.region"}},
+		{input: "cost-type", want: GroupBy{Type: ColumnGroupBy, Key: "cost_type"}},
+		{input: "label:team", want: GroupBy{Type: LabelGroupBy, Key: "team"}},
+		{input: "project-label:cost-center", want: GroupBy{Type: ProjectLabelGroupBy, Key: "cost-center"}},
+		{input: "label:", wantErr: true},
+		{input: "tag:team", wantErr: true},
+		{input: "cost", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseGroupBy(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error: got %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupByString(t *testing.T) {
+	for _, s := range []string{"service", "project", "project-name", "sku", "region", "cost-type", "label:team", "project-label:env"} {
+		g, err := ParseGroupBy(s)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", s, err)
+		}
+		if got := g.String(); got != s {
+			t.Errorf("got %q, want %q", got, s)
+		}
+	}
+}
+
+func TestParseGroupBysDefault(t *testing.T) {
+	got, err := ParseGroupBys(nil)
+	if err != nil || !reflect.DeepEqual(got, []GroupBy{ServiceGroupBy}) {
+		t.Errorf("got %v, %v; want service", got, err)
+	}
+}
+
+func TestGetCostsGroupByLabel(t *testing.T) {
+	mock := &mockBigQuery{
+		rows: []any{
+			costRow{Keys: []string{"my-project", Untagged}, Unit: "USD", Gross: 5},
+			costRow{Keys: []string{"my-project", "payments"}, Unit: "USD", Gross: 20},
+		},
+	}
+
+	client := NewClientWithAPI(mock, "my-project", testTable)
+	results, err := client.GetCosts(context.Background(), CostQuery{
+		Period:  period.LastDays(30, time.Now()),
+		GroupBy: []GroupBy{{Type: ColumnGroupBy, Key: "project.id"}, {Type: LabelGroupBy, Key: "team"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 2 || !reflect.DeepEqual(results[0].Keys, []string{"my-project", "payments"}) {
+		t.Errorf("results: got %+v", results)
+	}
+
+	q := mock.queries[0]
+	for _, s := range []string{
+		"IFNULL(project.id, @no_value) AS key_0",
+		"IFNULL((SELECT l.value FROM UNNEST(labels) l WHERE l.key = @label_1 LIMIT 1), @untagged) AS key_1",
+		"[key_0, key_1] AS keys",
+		"GROUP BY key_0, key_1, currency",
+	} {
+		if !strings.Contains(q.SQL, s) {
+			t.Errorf("query missing %q:\n%s", s, q.SQL)
+		}
+	}
+	for _, p := range q.Parameters {
+		if p.Name == "label_1" && p.Value != "team" {
+			t.Errorf("label parameter: got %v", p.Value)
+		}
+	}
+}
+
+func TestGetCostsGroupByMissingColumn(t *testing.T) {
+	mock := &mockBigQuery{meta: &bigquery.TableMetadata{Schema: exportSchema}}
+	client := NewClientWithAPI(mock, "my-project", testTable)
+
+	_, err := client.GetCosts(context.Background(), CostQuery{
+		Period:  period.LastDays(30, time.Now()),
+		GroupBy: []GroupBy{{Type: ProjectLabelGroupBy, Key: "env"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "project.labels.key") {
+		t.Errorf("error: got %v, want missing project.labels.key", err)
+	}
+}
+
+func TestQueryBuilderReusesParams(t *testing.T) {
+	var b queryBuilder
+	b.expression(GroupBy{Type: LabelGroupBy, Key: "team"}, "label_0")
+	b.expression(GroupBy{Type: ProjectLabelGroupBy, Key: "env"}, "label_1")
+
+	names := make(map[string]int)
+	for _, p := range b.params {
+		names[p.Name]++
+	}
+	if names["untagged"] != 1 || names["label_0"] != 1 || names["label_1"] != 1 {
+		t.Errorf("params: got %v", names)
+	}
+}
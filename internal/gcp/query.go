@@ -0,0 +1,133 @@
+package gcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/amayabdaniel/dab-cloudcost/internal/cache"
+)
+
+// Table is a fully qualified BigQuery table
+type Table struct {
+	Project string
+	Dataset string
+	Name    string
+}
+
+var (
+	// project IDs may be domain scoped, e.g. example.com:my-project
+	projectPattern = regexp.MustCompile(`^([a-z][a-z0-9.-]*:)?[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+	datasetPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	tablePattern   = regexp.MustCompile(`^[\p{L}\p{M}\p{N}\p{Pc}\p{Pd}]+$`)
+)
+
+// ParseTable parses a project.dataset.table name, optionally in backticks
+func ParseTable(s string) (Table, error) {
+	name := strings.TrimSpace(s)
+	if len(name) >= 2 && strings.HasPrefix(name, "`") && strings.HasSuffix(name, "`") {
+		name = name[1 : len(name)-1]
+	}
+
+	// split from the right, since domain-scoped projects contain dots
+	last := strings.LastIndex(name, ".")
+	if last < 0 {
+		return Table{}, fmt.Errorf("invalid billing table %q (want project.dataset.table)", s)
+	}
+	middle := strings.LastIndex(name[:last], ".")
+	if middle < 0 {
+		return Table{}, fmt.Errorf("invalid billing table %q (want project.dataset.table)", s)
+	}
+	t := Table{Project: name[:middle], Dataset: name[middle+1 : last], Name: name[last+1:]}
+
+	if !projectPattern.MatchString(t.Project) {
+		return Table{}, fmt.Errorf("invalid project %q in billing table %q", t.Project, s)
+	}
+	if !datasetPattern.MatchString(t.Dataset) {
+		return Table{}, fmt.Errorf("invalid dataset %q in billing table %q", t.Dataset, s)
+	}
+	if !tablePattern.MatchString(t.Name) {
+		return Table{}, fmt.Errorf("invalid table %q in billing table %q", t.Name, s)
+	}
+	return t, nil
+}
+
+// String returns the table as project.dataset.table
+func (t Table) String() string {
+	return t.Project + "." + t.Dataset + "." + t.Name
+}
+
+// Quoted returns the table quoted for use in SQL. ParseTable guarantees the
+// name contains no backticks.
+func (t Table) Quoted() string {
+	return "`" + t.String() + "`"
+}
+
+// exportColumns are the billing export columns every cost query relies on
+var exportColumns = []string{"service.description", "cost", "currency", "usage_start_time", "credits.amount"}
+
+// checkSchema reports columns missing from schema
+func checkSchema(t Table, schema bigquery.Schema, columns ...string) error {
+	var missing []string
+	for _, column := range columns {
+		if !hasColumn(schema, column) {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("table %s is not a billing export: missing %s", t, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// hasColumn reports whether schema has the possibly nested column path, e.g.
+// service.description
+func hasColumn(schema bigquery.Schema, path string) bool {
+	name, rest, nested := strings.Cut(path, ".")
+	for _, f := range schema {
+		if !strings.EqualFold(f.Name, name) {
+			continue
+		}
+		if !nested {
+			return true
+		}
+		return hasColumn(f.Schema, rest)
+	}
+	return false
+}
+
+// queryBuilder collects named parameters while a query is built, so values
+// never end up in the SQL text
+type queryBuilder struct {
+	params []bigquery.QueryParameter
+}
+
+// param adds a parameter with value and returns its placeholder. Adding a
+// name again reuses the first parameter, so constants can be added wherever
+// they are used.
+func (b *queryBuilder) param(name string, value any) string {
+	for _, p := range b.params {
+		if p.Name == name {
+			return "@" + name
+		}
+	}
+	b.params = append(b.params, bigquery.QueryParameter{Name: name, Value: value})
+	return "@" + name
+}
+
+// cacheKey returns the cache key of q run in project, covering both the SQL
+// text and the parameter values
+func (q Query) cacheKey(project string) (string, error) {
+	params, err := json.Marshal(q.Parameters)
+	if err != nil {
+		return "", err
+	}
+	return cache.Key("gcp", project, q.SQL, string(params)), nil
+}
+
+// query returns sql with the collected parameters
+func (b *queryBuilder) query(sql string) Query {
+	return Query{SQL: sql, Parameters: b.params}
+}
@@ -0,0 +1,72 @@
+package gcp
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func TestParseTable(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Table
+		wantErr bool
+	}{
+		{input: "my-project.billing.gcp_billing_export_v1_0123AB_4567CD_89EF01", want: Table{Project: "my-project", Dataset: "billing", Name: "gcp_billing_export_v1_0123AB_4567CD_89EF01"}},
+		{input: "`my-project.billing.export`", want: Table{Project: "my-project", Dataset: "billing", Name: "export"}},
+		{input: "example.com:my-project.billing.export", want: Table{Project: "example.com:my-project", Dataset: "billing", Name: "export"}},
+		{input: "billing.export", wantErr: true},
+		{input: "my-project.billing.export` WHERE 1=1 --", wantErr: true},
+		{input: "my-project.bill-ing.export", wantErr: true},
+		{input: "My_Project.billing.export", wantErr: true},
+		{input: "my-project.billing.", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseTable(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error: got %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableQuoted(t *testing.T) {
+	table := Table{Project: "my-project", Dataset: "billing", Name: "export"}
+	if got := table.Quoted(); got != "`my-project.billing.export`" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	if err := checkSchema(testTable, exportSchema, exportColumns...); err != nil {
+		t.Errorf("export schema: unexpected error: %v", err)
+	}
+
+	flat := bigquery.Schema{{Name: "service"}, {Name: "cost"}, {Name: "currency"}, {Name: "usage_start_time"}, {Name: "credits", Schema: bigquery.Schema{{Name: "amount"}}}}
+	if err := checkSchema(testTable, flat, exportColumns...); err == nil {
+		t.Error("service without description: expected error, got nil")
+	}
+}
+
+func TestQueryCacheKey(t *testing.T) {
+	var march, april queryBuilder
+	sql := "SELECT 1 WHERE d = " + march.param("d", "2024-03-01")
+	april.param("d", "2024-04-01")
+
+	a, err := march.query(sql).cacheKey("my-project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := april.query(sql).cacheKey("my-project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Error("different parameters: got the same key")
+	}
+}
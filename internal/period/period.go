@@ -0,0 +1,145 @@
+// Package period resolves the reporting window shared by the aws and gcp
+// commands. Ranges are whole days in UTC, the timezone both Cost Explorer and
+// the BigQuery billing export use, and End is exclusive.
+package period
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DateLayout is the format of dates accepted and printed by this package
+const DateLayout = "2006-01-02"
+
+// Named periods accepted by Spec.Name
+const (
+	MonthToDate   = "mtd"
+	LastMonth     = "last-month"
+	QuarterToDate = "qtd"
+	LastQuarter   = "last-quarter"
+	YearToDate    = "ytd"
+)
+
+// Names lists the named periods accepted by Spec.Name
+var Names = []string{MonthToDate, LastMonth, QuarterToDate, LastQuarter, YearToDate}
+
+// Range is a span of whole UTC days from Start (inclusive) to End (exclusive)
+type Range struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Spec describes a window as given on the command line. Name or Start take
+// precedence over Days; End is inclusive and defaults to yesterday.
+type Spec struct {
+	Days  int
+	Start string
+	End   string
+	Name  string
+}
+
+// Contains reports whether t falls within r
+func (r Range) Contains(t time.Time) bool {
+	return !t.Before(r.Start) && t.Before(r.End)
+}
+
+// String formats r with an inclusive end date, e.g. "2024-01-01 to 2024-01-31"
+func (r Range) String() string {
+	return r.Start.Format(DateLayout) + " to " + r.End.AddDate(0, 0, -1).Format(DateLayout)
+}
+
+// LastDays returns the days days before today, excluding today
+func LastDays(days int, now time.Time) Range {
+	today := truncateDay(now)
+	return Range{Start: today.AddDate(0, 0, -days), End: today}
+}
+
+// RestOfMonth returns today through the last day of the current month
+func RestOfMonth(now time.Time) Range {
+	today := truncateDay(now)
+	month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return Range{Start: today, End: month.AddDate(0, 1, 0)}
+}
+
+// NextDays returns the days days starting today
+func NextDays(days int, now time.Time) Range {
+	today := truncateDay(now)
+	return Range{Start: today, End: today.AddDate(0, 0, days)}
+}
+
+// Resolve turns spec into a Range relative to now. Periods that run up to
+// today end before today, unless today is their first day, in which case they
+// cover today so the range is never empty.
+func Resolve(spec Spec, now time.Time) (Range, error) {
+	today := truncateDay(now)
+
+	switch {
+	case spec.Name != "" && (spec.Start != "" || spec.End != ""):
+		return Range{}, fmt.Errorf("--period cannot be combined with --start or --end")
+	case spec.Name != "":
+		return named(spec.Name, today)
+	case spec.Start != "":
+		return explicit(spec.Start, spec.End, today)
+	case spec.End != "":
+		return Range{}, fmt.Errorf("--end requires --start")
+	}
+
+	if spec.Days <= 0 {
+		return Range{}, fmt.Errorf("days must be positive, got %d", spec.Days)
+	}
+	return LastDays(spec.Days, today), nil
+}
+
+func named(name string, today time.Time) (Range, error) {
+	month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	quarter := time.Date(today.Year(), today.Month()-(today.Month()-1)%3, 1, 0, 0, 0, 0, time.UTC)
+	year := time.Date(today.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+
+	switch strings.ToLower(name) {
+	case MonthToDate, "month-to-date":
+		return toDate(month, today), nil
+	case LastMonth:
+		return Range{Start: month.AddDate(0, -1, 0), End: month}, nil
+	case QuarterToDate, "quarter-to-date":
+		return toDate(quarter, today), nil
+	case LastQuarter:
+		return Range{Start: quarter.AddDate(0, -3, 0), End: quarter}, nil
+	case YearToDate, "year-to-date":
+		return toDate(year, today), nil
+	}
+	return Range{}, fmt.Errorf("unknown period %q (want one of %s)", name, strings.Join(Names, ", "))
+}
+
+func toDate(start, today time.Time) Range {
+	if !today.After(start) {
+		return Range{Start: start, End: start.AddDate(0, 0, 1)}
+	}
+	return Range{Start: start, End: today}
+}
+
+func explicit(start, end string, today time.Time) (Range, error) {
+	s, err := time.Parse(DateLayout, start)
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid --start %q (want YYYY-MM-DD)", start)
+	}
+
+	e := today
+	if end != "" {
+		inclusive, err := time.Parse(DateLayout, end)
+		if err != nil {
+			return Range{}, fmt.Errorf("invalid --end %q (want YYYY-MM-DD)", end)
+		}
+		e = inclusive.AddDate(0, 0, 1)
+	}
+
+	if !e.After(s) {
+		return Range{}, fmt.Errorf("--start %s must be before --end", start)
+	}
+	return Range{Start: s, End: e}, nil
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
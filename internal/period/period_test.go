@@ -0,0 +1,170 @@
+package period
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolve(t *testing.T) {
+	now := time.Date(2024, 5, 15, 18, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		spec      Spec
+		now       time.Time
+		wantStart string
+		wantEnd   string
+		wantErr   bool
+	}{
+		{
+			name:      "last days",
+			spec:      Spec{Days: 30},
+			wantStart: "2024-04-15",
+			wantEnd:   "2024-05-15",
+		},
+		{
+			name:      "start and inclusive end",
+			spec:      Spec{Start: "2024-01-01", End: "2024-01-31"},
+			wantStart: "2024-01-01",
+			wantEnd:   "2024-02-01",
+		},
+		{
+			name:      "start only runs to today",
+			spec:      Spec{Start: "2024-05-01"},
+			wantStart: "2024-05-01",
+			wantEnd:   "2024-05-15",
+		},
+		{
+			name:      "month to date",
+			spec:      Spec{Name: "mtd"},
+			wantStart: "2024-05-01",
+			wantEnd:   "2024-05-15",
+		},
+		{
+			name:      "month to date on the first",
+			spec:      Spec{Name: "mtd"},
+			now:       time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC),
+			wantStart: "2024-05-01",
+			wantEnd:   "2024-05-02",
+		},
+		{
+			name:      "last month across year",
+			spec:      Spec{Name: "last-month"},
+			now:       time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC),
+			wantStart: "2023-12-01",
+			wantEnd:   "2024-01-01",
+		},
+		{
+			name:      "quarter to date",
+			spec:      Spec{Name: "QTD"},
+			wantStart: "2024-04-01",
+			wantEnd:   "2024-05-15",
+		},
+		{
+			name:      "last quarter",
+			spec:      Spec{Name: "last-quarter"},
+			wantStart: "2024-01-01",
+			wantEnd:   "2024-04-01",
+		},
+		{
+			name:      "year to date",
+			spec:      Spec{Name: "ytd"},
+			wantStart: "2024-01-01",
+			wantEnd:   "2024-05-15",
+		},
+		{
+			name:      "non utc clock uses utc day",
+			spec:      Spec{Days: 1},
+			now:       time.Date(2024, 5, 15, 22, 0, 0, 0, time.FixedZone("PDT", -7*3600)),
+			wantStart: "2024-05-15",
+			wantEnd:   "2024-05-16",
+		},
+		{
+			name:    "unknown period",
+			spec:    Spec{Name: "fortnight"},
+			wantErr: true,
+		},
+		{
+			name:    "period with start",
+			spec:    Spec{Name: "mtd", Start: "2024-01-01"},
+			wantErr: true,
+		},
+		{
+			name:    "end without start",
+			spec:    Spec{End: "2024-01-31"},
+			wantErr: true,
+		},
+		{
+			name:    "end before start",
+			spec:    Spec{Start: "2024-02-01", End: "2024-01-01"},
+			wantErr: true,
+		},
+		{
+			name:    "bad date",
+			spec:    Spec{Start: "01/02/2024"},
+			wantErr: true,
+		},
+		{
+			name:    "zero days",
+			spec:    Spec{Days: 0},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := now
+			if !tt.now.IsZero() {
+				clock = tt.now
+			}
+
+			r, err := Resolve(tt.spec, clock)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := r.Start.Format(DateLayout); got != tt.wantStart {
+				t.Errorf("start: got %s, want %s", got, tt.wantStart)
+			}
+			if got := r.End.Format(DateLayout); got != tt.wantEnd {
+				t.Errorf("end: got %s, want %s", got, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestRange(t *testing.T) {
+	r := Range{
+		Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	if got := r.String(); got != "2024-01-01 to 2024-01-31" {
+		t.Errorf("string: got %s", got)
+	}
+	if !r.Contains(time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC)) {
+		t.Error("expected range to contain 2024-01-31")
+	}
+	if r.Contains(r.End) {
+		t.Error("expected range to exclude its end")
+	}
+}
+
+func TestUpcoming(t *testing.T) {
+	now := time.Date(2024, 2, 20, 9, 0, 0, 0, time.UTC)
+
+	rest := RestOfMonth(now)
+	if rest.String() != "2024-02-20 to 2024-02-29" {
+		t.Errorf("rest of month: got %s", rest)
+	}
+
+	next := NextDays(14, now)
+	if next.String() != "2024-02-20 to 2024-03-04" {
+		t.Errorf("next days: got %s", next)
+	}
+}